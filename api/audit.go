@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/gorilla/mux"
+)
+
+// MaxAuditChunks limits how many chunks one audit request can ask for
+const MaxAuditChunks = 64
+
+// auditRequest is the body of an /audit request. The nonce is echoed back so
+// the client can match the response to its request.
+type auditRequest struct {
+	Nonce  string `json:"nonce"`
+	Chunks []int  `json:"chunks"`
+}
+
+type auditChunk struct {
+	Index      int      `json:"index"`
+	Data       []byte   `json:"data"`
+	Proof      []string `json:"proof"`
+	Directions []bool   `json:"directions"`
+}
+
+type auditResponse struct {
+	Nonce  string       `json:"nonce"`
+	Chunks []auditChunk `json:"chunks"`
+}
+
+// AuditHandler answers a storage audit challenge for a file, POST /audit/{index}
+func (h *Handlers) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil || index < 0 || index >= h.Server.GetFileCount() {
+		writeJSONError(w, "Invalid file index", http.StatusBadRequest)
+		return
+	}
+
+	var request auditRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid audit request", http.StatusBadRequest)
+		return
+	}
+	if nonce, err := hex.DecodeString(request.Nonce); err != nil || len(nonce) < 16 {
+		writeJSONError(w, "Nonce must be at least 16 hex-encoded bytes", http.StatusBadRequest)
+		return
+	}
+	if len(request.Chunks) == 0 || len(request.Chunks) > MaxAuditChunks {
+		writeJSONError(w, "Request between 1 and "+strconv.Itoa(MaxAuditChunks)+" chunks", http.StatusBadRequest)
+		return
+	}
+
+	proofs, err := h.Server.AuditFile(index, request.Chunks)
+	if errors.Is(err, server.ErrQuarantined) {
+		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := auditResponse{Nonce: request.Nonce, Chunks: make([]auditChunk, len(proofs))}
+	for i, proof := range proofs {
+		chunk := auditChunk{Index: proof.Index, Data: proof.Data, Directions: proof.Directions}
+		for _, hash := range proof.Proof {
+			chunk.Proof = append(chunk.Proof, hex.EncodeToString(hash[:]))
+		}
+		response.Chunks[i] = chunk
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
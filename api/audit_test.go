@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/gorilla/mux"
+)
+
+func TestAuditHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+	router := mux.NewRouter()
+	router.HandleFunc("/audit/{index}", handler.AuditHandler)
+
+	nonce := strings.Repeat("ab", 16)
+	mockServer.On("GetFileCount").Return(1)
+	mockServer.On("AuditFile", 0, []int{2}).Return([]server.ChunkProof{
+		{Index: 2, Data: []byte("chunk"), Proof: [][32]byte{{0xcd}}, Directions: []bool{true}},
+	}, nil)
+
+	body := `{"nonce":"` + nonce + `","chunks":[2]}`
+	req, _ := http.NewRequest("POST", "/audit/0", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response auditResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Nonce != nonce || len(response.Chunks) != 1 {
+		t.Fatalf("Unexpected response: %+v", response)
+	}
+	chunk := response.Chunks[0]
+	if chunk.Index != 2 || string(chunk.Data) != "chunk" || !strings.HasPrefix(chunk.Proof[0], "cd00") {
+		t.Errorf("Unexpected chunk in response: %+v", chunk)
+	}
+
+	for path, body := range map[string]string{
+		"/audit/1": `{"nonce":"` + nonce + `","chunks":[0]}`,
+		"/audit/0": `{"nonce":"abcd","chunks":[0]}`,
+		"/audit/x": `{"nonce":"` + nonce + `","chunks":[0]}`,
+	} {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", path, body, rr.Code, http.StatusBadRequest)
+		}
+	}
+	for _, chunks := range []string{`[]`, `[` + strings.Repeat("0,", MaxAuditChunks) + `0]`} {
+		req, _ := http.NewRequest("POST", "/audit/0", strings.NewReader(`{"nonce":"`+nonce+`","chunks":`+chunks+`}`))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", chunks, rr.Code, http.StatusBadRequest)
+		}
+	}
+	mockServer.AssertExpectations(t)
+}
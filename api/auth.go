@@ -0,0 +1,231 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Scope is a permission granted to an API key or token
+type Scope string
+
+const (
+	// ScopeUpload allows adding files and timestamps to a tree
+	ScopeUpload Scope = "upload"
+	// ScopeRead allows downloading files and reading proofs and tree heads
+	ScopeRead Scope = "read"
+	// ScopeAdmin allows the admin API and implies every other scope
+	ScopeAdmin Scope = "admin"
+)
+
+// minTokenSecret is the shortest token secret accepted, so tokens can't be forged by guessing it
+const minTokenSecret = 32
+
+var (
+	// ErrInvalidToken is returned for a token that is malformed or not signed with the secret
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for a correctly signed token past its expiry
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// TokenClaims is the signed payload of a bearer token
+type TokenClaims struct {
+	Subject string  `json:"sub"`
+	Scopes  []Scope `json:"scopes"`
+	// ExpiresAt is the expiry in Unix seconds; 0 means the token never expires
+	ExpiresAt int64 `json:"exp,omitempty"`
+	// Namespace restricts the token to the routes of one namespace; empty allows every route
+	Namespace string `json:"ns,omitempty"`
+}
+
+// grant is what a credential allows
+type grant struct {
+	scopes []Scope
+	// namespace is the only namespace the credential may use, if set
+	namespace string
+}
+
+// Authenticator checks the credentials of API requests: static API keys and
+// bearer tokens signed with HMAC-SHA256. A nil Authenticator lets every
+// request through.
+type Authenticator struct {
+	// keys maps the SHA-256 of every API key to what it grants, so looking
+	// a key up doesn't compare the key itself
+	keys        map[[32]byte]grant
+	tokenSecret []byte
+	now         func() time.Time
+}
+
+// NewAuthenticator accepts the API keys, each written as key:scope+scope or
+// key:scope+scope@namespace to restrict the key to one namespace, and tokens
+// signed with tokenSecret. Without either it returns nil, which leaves the
+// API open.
+func NewAuthenticator(apiKeys []string, tokenSecret string) (*Authenticator, error) {
+	if len(apiKeys) == 0 && tokenSecret == "" {
+		return nil, nil
+	}
+	if tokenSecret != "" && len(tokenSecret) < minTokenSecret {
+		return nil, fmt.Errorf("token secret must be at least %d bytes", minTokenSecret)
+	}
+
+	a := &Authenticator{keys: map[[32]byte]grant{}, tokenSecret: []byte(tokenSecret), now: time.Now}
+	for _, entry := range apiKeys {
+		key, scopes, ok := strings.Cut(entry, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("API key must be written as key:scope+scope or key:scope+scope@namespace")
+		}
+		scopes, namespace, restricted := strings.Cut(scopes, "@")
+		if restricted && namespace == "" {
+			return nil, fmt.Errorf("API key restricted to an empty namespace")
+		}
+		parsed, err := ParseScopes(strings.Split(scopes, "+"))
+		if err != nil {
+			return nil, err
+		}
+		a.keys[sha256.Sum256([]byte(key))] = grant{scopes: parsed, namespace: namespace}
+	}
+	return a, nil
+}
+
+// ParseScopes checks that every name is a known scope
+func ParseScopes(names []string) ([]Scope, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no scopes given")
+	}
+	scopes := make([]Scope, len(names))
+	for i, name := range names {
+		scope := Scope(strings.TrimSpace(name))
+		if scope != ScopeUpload && scope != ScopeRead && scope != ScopeAdmin {
+			return nil, fmt.Errorf("unknown scope %q: use upload, read or admin", name)
+		}
+		scopes[i] = scope
+	}
+	return scopes, nil
+}
+
+// IssueToken signs claims with secret into a bearer token
+func IssueToken(secret string, claims TokenClaims) (string, error) {
+	if len(secret) < minTokenSecret {
+		return "", fmt.Errorf("token secret must be at least %d bytes", minTokenSecret)
+	}
+	if _, err := ParseScopes(scopesToNames(claims.Scopes)); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(tokenMAC([]byte(secret), encoded)), nil
+}
+
+// VerifyToken checks the signature and expiry of a token and returns its claims
+func (a *Authenticator) VerifyToken(token string) (*TokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || len(a.tokenSecret) == 0 {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, tokenMAC(a.tokenSecret, encoded)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt != 0 && a.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+// Require lets a request through to next only if its credentials grant scope.
+// Credentials are sent as "Authorization: Bearer <API key or token>" or in
+// an X-API-Key header. Credentials restricted to a namespace are refused.
+func (a *Authenticator) Require(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return a.require(scope, false, next)
+}
+
+// RequireInNamespace is Require for the routes under /ns/{namespace}, which
+// also accept credentials restricted to the namespace in the path
+func (a *Authenticator) RequireInNamespace(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return a.require(scope, true, next)
+}
+
+func (a *Authenticator) require(scope Scope, inNamespace bool, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		granted, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-merkle"`)
+			writeJSONError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !slices.Contains(granted.scopes, scope) && !slices.Contains(granted.scopes, ScopeAdmin) {
+			writeJSONError(w, fmt.Sprintf("credentials lack the %s scope", scope), http.StatusForbidden)
+			return
+		}
+		if granted.namespace != "" && (!inNamespace || mux.Vars(r)["namespace"] != granted.namespace) {
+			writeJSONError(w, fmt.Sprintf("credentials are restricted to namespace %s", granted.namespace), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// authenticate returns what the request's credentials grant
+func (a *Authenticator) authenticate(r *http.Request) (grant, error) {
+	credential := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); credential == "" && auth != "" {
+		scheme, value, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return grant{}, errors.New("authorization must use the Bearer scheme")
+		}
+		credential = strings.TrimSpace(value)
+	}
+	if credential == "" {
+		return grant{}, errors.New("missing API key or bearer token")
+	}
+
+	if granted, ok := a.keys[sha256.Sum256([]byte(credential))]; ok {
+		return granted, nil
+	}
+	if !strings.Contains(credential, ".") {
+		return grant{}, errors.New("invalid API key")
+	}
+	claims, err := a.VerifyToken(credential)
+	if err != nil {
+		return grant{}, err
+	}
+	return grant{scopes: claims.Scopes, namespace: claims.Namespace}, nil
+}
+
+func tokenMAC(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func scopesToNames(scopes []Scope) []string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return names
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestAuthenticatorConfig(t *testing.T) {
+	if auth, err := NewAuthenticator(nil, ""); auth != nil || err != nil {
+		t.Errorf("NewAuthenticator: Expected nil without keys or secret, got %v (%v)", auth, err)
+	}
+	for _, keys := range [][]string{{"nokey"}, {":read"}, {"key:write"}, {"key:read@"}} {
+		if _, err := NewAuthenticator(keys, ""); err == nil {
+			t.Errorf("NewAuthenticator(%q): Expected error", keys)
+		}
+	}
+	if _, err := NewAuthenticator(nil, "short"); err == nil {
+		t.Error("NewAuthenticator: Expected error for a short token secret")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	auth, _ := NewAuthenticator(nil, testSecret)
+	auth.now = func() time.Time { return time.Unix(1000, 0) }
+
+	token, err := IssueToken(testSecret, TokenClaims{Subject: "ci", Scopes: []Scope{ScopeUpload}, ExpiresAt: 2000})
+	if err != nil {
+		t.Fatalf("IssueToken: Unexpected error: %v", err)
+	}
+	claims, err := auth.VerifyToken(token)
+	if err != nil || claims.Subject != "ci" || len(claims.Scopes) != 1 || claims.Scopes[0] != ScopeUpload {
+		t.Errorf("VerifyToken: Unexpected claims %+v (%v)", claims, err)
+	}
+
+	forged, _ := IssueToken(strings.Repeat("x", 32), TokenClaims{Scopes: []Scope{ScopeAdmin}})
+	payload, signature, _ := strings.Cut(token, ".")
+	for name, bad := range map[string]string{
+		"forged":   forged,
+		"tampered": payload + "x." + signature,
+		"unsigned": payload,
+	} {
+		if _, err := auth.VerifyToken(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyToken(%s): Expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	auth.now = func() time.Time { return time.Unix(2000, 0) }
+	if _, err := auth.VerifyToken(token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("VerifyToken: Expected ErrTokenExpired, got %v", err)
+	}
+	if _, err := IssueToken(testSecret, TokenClaims{Scopes: []Scope{"write"}}); err == nil {
+		t.Error("IssueToken: Expected error for an unknown scope")
+	}
+}
+
+func TestAuthRoutes(t *testing.T) {
+	auth, _ := NewAuthenticator([]string{"reader-key:read", "admin-key:admin"}, testSecret)
+	uploader, _ := IssueToken(testSecret, TokenClaims{Subject: "ci", Scopes: []Scope{ScopeUpload}})
+	srv := server.NewServer()
+	srv.UploadFile("a.txt", []byte("hello"))
+	router := NewRouter(srv, RouterOptions{Auth: auth, AllowedOrigins: []string{"https://app.example.com"}})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"no credentials", "GET", "/download/0", "", "", http.StatusUnauthorized},
+		{"unknown key", "GET", "/download/0", "X-API-Key", "guess", http.StatusUnauthorized},
+		{"basic scheme", "GET", "/download/0", "Authorization", "Basic cmVhZGVyLWtleQ==", http.StatusUnauthorized},
+		{"read key", "GET", "/download/0", "X-API-Key", "reader-key", http.StatusOK},
+		{"read key as bearer", "GET", "/proof/0", "Authorization", "Bearer reader-key", http.StatusOK},
+		{"read key uploading", "POST", "/timestamp", "X-API-Key", "reader-key", http.StatusForbidden},
+		{"upload token reading", "GET", "/download/0", "Authorization", "Bearer " + uploader, http.StatusForbidden},
+		{"upload token", "POST", "/timestamp", "Authorization", "Bearer " + uploader, http.StatusBadRequest},
+		{"read key on admin", "GET", "/admin/scrub", "X-API-Key", "reader-key", http.StatusForbidden},
+		{"admin key on admin", "GET", "/admin/scrub", "X-API-Key", "admin-key", http.StatusOK},
+		{"admin key reading", "GET", "/download/0", "X-API-Key", "admin-key", http.StatusOK},
+		{"preflight", "OPTIONS", "/upload", "Origin", "https://app.example.com", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Fatalf("got %v want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+			if rr.Code == http.StatusUnauthorized || rr.Code == http.StatusForbidden {
+				var response map[string]string
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response["error"] == "" {
+					t.Errorf("Expected a JSON error, got %q", rr.Body.String())
+				}
+			}
+			if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestNamespaceCredentials(t *testing.T) {
+	auth, _ := NewAuthenticator([]string{"team-a-key:read+upload@team-a", "team-a-admin:admin@team-a", "admin-key:admin"}, testSecret)
+	teamB, _ := IssueToken(testSecret, TokenClaims{Subject: "ci", Scopes: []Scope{ScopeRead}, Namespace: "team-b"})
+	namespaces, _ := server.NewNamespaces(context.Background(), "", func(context.Context, string) (*server.Server, error) {
+		srv := server.NewServer()
+		srv.UploadFile("a.txt", []byte("hello"))
+		return srv, nil
+	})
+	namespaces.Create("team-a", 0)
+	namespaces.Create("team-b", 0)
+	global := server.NewServer()
+	global.UploadFile("a.txt", []byte("hello"))
+	router := NewRouter(global, RouterOptions{Namespaces: namespaces, Auth: auth})
+
+	tests := []struct {
+		name       string
+		path       string
+		credential string
+		want       int
+	}{
+		{"own namespace", "/ns/team-a/download/0", "team-a-key", http.StatusOK},
+		{"other namespace", "/ns/team-b/download/0", "team-a-key", http.StatusForbidden},
+		{"global tree", "/download/0", "team-a-key", http.StatusForbidden},
+		{"admin restricted to a namespace", "/admin/namespaces", "team-a-admin", http.StatusForbidden},
+		{"admin restricted to its own namespace info", "/admin/namespaces/team-a", "team-a-admin", http.StatusForbidden},
+		{"token for own namespace", "/ns/team-b/download/0", teamB, http.StatusOK},
+		{"token for other namespace", "/ns/team-a/download/0", teamB, http.StatusForbidden},
+		{"unrestricted key", "/ns/team-a/download/0", "admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("got %v want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	srv := server.NewServer()
+	srv.ReadOnly = true
+	router := SetupRoutes(srv)
+
+	for path, want := range map[string]int{
+		"/download/0":        http.StatusBadRequest,
+		"/proof/x":           http.StatusBadRequest,
+		"/tree/node?level=x": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response map[string]string
+		if rr.Code != want || rr.Header().Get("Content-Type") != "application/json" ||
+			json.Unmarshal(rr.Body.Bytes(), &response) != nil || response["error"] == "" {
+			t.Errorf("%s: Expected a %d JSON error, got %d %q", path, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	handler := CORS([]string{"https://app.example.com"})(func(w http.ResponseWriter, r *http.Request) {})
+	for origin, want := range map[string]string{
+		"https://app.example.com":  "https://app.example.com",
+		"https://evil.example.com": "",
+	} {
+		req, _ := http.NewRequest("GET", "/root", nil)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("Origin %s: got Access-Control-Allow-Origin %q want %q", origin, got, want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	CORSMiddleware(func(w http.ResponseWriter, r *http.Request) {})(rr, httptest.NewRequest("GET", "/root", nil))
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("CORSMiddleware: got Access-Control-Allow-Origin %q want *", got)
+	}
+
+	// Without configured origins the API stays same-origin only
+	req, _ := http.NewRequest("OPTIONS", "/root", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr = httptest.NewRecorder()
+	CORS(nil)(func(w http.ResponseWriter, r *http.Request) {})(rr, req)
+	for _, header := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+		if got := rr.Header().Get(header); got != "" {
+			t.Errorf("CORS(nil): got %s %q want none", header, got)
+		}
+	}
+}
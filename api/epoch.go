@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultEpochWait is how long GET /epoch waits for the requested epoch when no timeout is given
+	DefaultEpochWait = 30 * time.Second
+	// MaxEpochWait caps the timeout a client can ask GET /epoch to wait
+	MaxEpochWait = 60 * time.Second
+)
+
+// EpochHandler long-polls for a sealed epoch, GET /epoch?wait=&timeout=. It
+// responds as soon as epoch wait has been sealed, or with the latest epoch
+// once the timeout expires; clients compare the returned epoch number.
+func (h *Handlers) EpochHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	wait := 0
+	if raw := query.Get("wait"); raw != "" {
+		var err error
+		wait, err = strconv.Atoi(raw)
+		if err != nil || wait < 0 {
+			writeJSONError(w, "Invalid epoch", http.StatusBadRequest)
+			return
+		}
+	}
+	timeout := DefaultEpochWait
+	if raw := query.Get("timeout"); raw != "" {
+		var err error
+		timeout, err = time.ParseDuration(raw)
+		if err != nil || timeout < 0 {
+			writeJSONError(w, "Invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = min(timeout, MaxEpochWait)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	epoch, err := h.Server.WaitForEpoch(ctx, wait)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		// The client went away
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(epoch)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/akhilesharora/go-merkle/internal/server"
 	"github.com/gorilla/mux"
@@ -12,39 +15,69 @@ import (
 
 type Handlers struct {
 	Server server.ServerInterface
+	// Namespaces holds the namespaces managed through the admin API, if enabled
+	Namespaces *server.Namespaces
 }
 
 func (h *Handlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseMultipartForm(10 << 20) // 10 MB
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	file, _, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer file.Close()
 
 	data, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	filename := r.URL.Query().Get("filename")
-	fileIndex := h.Server.UploadFile(filename, data)
+	fileIndex, err := h.Server.UploadFile(filename, data)
+	if errors.Is(err, server.ErrReadOnly) {
+		writeJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, server.ErrQuotaExceeded) {
+		writeJSONError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	epoch, err := h.Server.EpochOf(int(fileIndex))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	response := map[string]interface{}{
 		"message":   "File uploaded successfully",
 		"fileIndex": fileIndex,
+		"epoch":     epoch,
+	}
+	promise, err := h.Server.IssueInclusionPromise(int(fileIndex))
+	switch {
+	case err == nil:
+		response["promise"] = promise
+	case !errors.Is(err, server.ErrNoSigningKey):
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -53,44 +86,137 @@ func (h *Handlers) DownloadHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	index, err := strconv.Atoi(vars["index"])
 	if err != nil || index < 0 || index >= h.Server.GetFileCount() {
-		http.Error(w, "Invalid file index", http.StatusBadRequest)
+		writeJSONError(w, "Invalid file index", http.StatusBadRequest)
 		return
 	}
 
+	// Files stored compressed are served as they are to clients that accept gzip
+	w.Header().Set("Vary", "Accept-Encoding")
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		compressed, ok, err := h.Server.GetFileGzip(index)
+		if errors.Is(err, server.ErrQuarantined) {
+			writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			w.Header().Set("Content-Encoding", "gzip")
+			_, err = w.Write(compressed)
+			if err != nil {
+				writeJSONError(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
 	fileData, err := h.Server.GetFileData(index)
+	if errors.Is(err, server.ErrQuarantined) {
+		writeJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	_, err = w.Write(fileData)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// acceptsGzip reports whether an Accept-Encoding header allows gzip. An
+// explicit gzip entry takes precedence over "*", and q=0 refuses a coding.
+func acceptsGzip(header string) bool {
+	gzipAllowed, wildcardAllowed := false, false
+	gzipListed, wildcardListed := false, false
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		allowed := true
+		if q, found := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); found {
+			weight, err := strconv.ParseFloat(q, 64)
+			allowed = err == nil && weight > 0
+		}
+		if coding == "gzip" {
+			gzipListed, gzipAllowed = true, allowed
+		} else {
+			wildcardListed, wildcardAllowed = true, allowed
+		}
+	}
+	if gzipListed {
+		return gzipAllowed
+	}
+	return wildcardListed && wildcardAllowed
+}
+
 func (h *Handlers) ProofHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	index, err := strconv.Atoi(vars["index"])
 	if err != nil || index < 0 || index >= h.Server.GetFileCount() {
-		http.Error(w, "Invalid file index", http.StatusBadRequest)
+		writeJSONError(w, "Invalid file index", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("treeSize") != "" {
+		h.versionedProof(w, r, index)
 		return
 	}
 
 	proof, directions, err := h.Server.GenerateMerkleProof(index)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"proof":      proof,
+		"directions": directions,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// versionedProof serves a proof against an earlier version of the tree selected by the treeSize query parameter
+func (h *Handlers) versionedProof(w http.ResponseWriter, r *http.Request, index int) {
+	treeSize, err := strconv.Atoi(r.URL.Query().Get("treeSize"))
+	if err != nil || index >= treeSize {
+		writeJSONError(w, "Invalid tree size", http.StatusBadRequest)
+		return
+	}
+
+	rootHash, err := h.Server.GetMerkleRootHashAt(treeSize)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	proof, directions, err := h.Server.GenerateMerkleProofAt(treeSize, index)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	response := map[string]interface{}{
 		"proof":      proof,
 		"directions": directions,
+		"treeSize":   treeSize,
+		"rootHash":   rootHash,
 	}
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
@@ -100,16 +226,43 @@ func (h *Handlers) ServeUI(w http.ResponseWriter, r *http.Request) {
 }
 
 func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	return CORS([]string{"*"})(next)
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+// CORS returns a middleware that allows cross-origin requests from the given
+// origins, or from any origin when they include "*". With no origins no CORS
+// headers are sent, so browsers only allow same-origin requests. Preflight
+// requests are answered without reaching next, so they need no credentials.
+func CORS(origins []string) func(http.HandlerFunc) http.HandlerFunc {
+	anyOrigin := slices.Contains(origins, "*")
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if len(origins) > 0 {
+				if anyOrigin {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Add("Vary", "Origin")
+					if origin := r.Header.Get("Origin"); slices.Contains(origins, origin) {
+						w.Header().Set("Access-Control-Allow-Origin", origin)
+					}
+				}
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+			}
+
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
 
-		next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r)
+		}
 	}
 }
+
+// writeJSONError writes an error as a JSON object with an error field
+func writeJSONError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
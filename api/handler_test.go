@@ -2,12 +2,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 )
@@ -17,9 +21,9 @@ type MockServer struct {
 	mock.Mock
 }
 
-func (m *MockServer) UploadFile(filename string, data []byte) uint {
+func (m *MockServer) UploadFile(filename string, data []byte) (uint, error) {
 	args := m.Called(filename, data)
-	return args.Get(0).(uint)
+	return args.Get(0).(uint), args.Error(1)
 }
 
 func (m *MockServer) GetFileData(index int) ([]byte, error) {
@@ -42,6 +46,85 @@ func (m *MockServer) GenerateMerkleProof(index int) ([][32]byte, []bool, error)
 	return args.Get(0).([][32]byte), args.Get(1).([]bool), args.Error(2)
 }
 
+func (m *MockServer) GetMerkleRootHashAt(treeSize int) ([32]byte, error) {
+	args := m.Called(treeSize)
+	return args.Get(0).([32]byte), args.Error(1)
+}
+
+func (m *MockServer) GenerateMerkleProofAt(treeSize, index int) ([][32]byte, []bool, error) {
+	args := m.Called(treeSize, index)
+	return args.Get(0).([][32]byte), args.Get(1).([]bool), args.Error(2)
+}
+
+func (m *MockServer) GetNodeHashes(treeSize, level int, indices []int) ([][32]byte, error) {
+	args := m.Called(treeSize, level, indices)
+	return args.Get(0).([][32]byte), args.Error(1)
+}
+
+func (m *MockServer) GetSignedTreeHead() (*treehead.SignedTreeHead, error) {
+	args := m.Called()
+	return args.Get(0).(*treehead.SignedTreeHead), args.Error(1)
+}
+
+func (m *MockServer) GetConsistencyProof(oldSize, newSize int) ([][32]byte, error) {
+	args := m.Called(oldSize, newSize)
+	return args.Get(0).([][32]byte), args.Error(1)
+}
+
+func (m *MockServer) IssueInclusionPromise(fileIndex int) (*treehead.InclusionPromise, error) {
+	args := m.Called(fileIndex)
+	promise, _ := args.Get(0).(*treehead.InclusionPromise)
+	return promise, args.Error(1)
+}
+
+func (m *MockServer) Timestamp(ctx context.Context, hash [32]byte) (*treehead.TimestampReceipt, error) {
+	args := m.Called(hash)
+	receipt, _ := args.Get(0).(*treehead.TimestampReceipt)
+	return receipt, args.Error(1)
+}
+
+func (m *MockServer) EpochOf(fileIndex int) (int, error) {
+	args := m.Called(fileIndex)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockServer) WaitForEpoch(ctx context.Context, epoch int) (*server.Epoch, error) {
+	args := m.Called(epoch)
+	return args.Get(0).(*server.Epoch), args.Error(1)
+}
+
+func (m *MockServer) ScrubFile(fileIndex int) (bool, error) {
+	args := m.Called(fileIndex)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockServer) ScrubStatus() server.ScrubStatus {
+	args := m.Called()
+	return args.Get(0).(server.ScrubStatus)
+}
+
+func (m *MockServer) GetFileGzip(fileIndex int) ([]byte, bool, error) {
+	args := m.Called(fileIndex)
+	data, _ := args.Get(0).([]byte)
+	return data, args.Bool(1), args.Error(2)
+}
+
+func (m *MockServer) CompressionStats() storage.CompressionStats {
+	args := m.Called()
+	return args.Get(0).(storage.CompressionStats)
+}
+
+func (m *MockServer) TreeMode() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockServer) AuditFile(fileIndex int, chunks []int) ([]server.ChunkProof, error) {
+	args := m.Called(fileIndex, chunks)
+	proofs, _ := args.Get(0).([]server.ChunkProof)
+	return proofs, args.Error(1)
+}
+
 func TestUploadHandler(t *testing.T) {
 	mockServer := new(MockServer)
 	handler := &Handlers{Server: mockServer}
@@ -62,7 +145,9 @@ func TestUploadHandler(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Set up the mock expectation
-	mockServer.On("UploadFile", "testfile.txt", []byte("file content")).Return(uint(0))
+	mockServer.On("UploadFile", "testfile.txt", []byte("file content")).Return(uint(0), nil)
+	mockServer.On("EpochOf", 0).Return(1, nil)
+	mockServer.On("IssueInclusionPromise", 0).Return(nil, server.ErrNoSigningKey)
 
 	handler.UploadHandler(rr, req)
 
@@ -146,3 +231,90 @@ func TestProofHandler(t *testing.T) {
 
 	mockServer.AssertExpectations(t)
 }
+
+// TestProofHandlerTreeSize tests ProofHandler serving a proof for an earlier tree version
+func TestProofHandlerTreeSize(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	mockServer.On("GetFileCount").Return(3)
+	mockServer.On("GetMerkleRootHashAt", 2).Return([32]byte{9}, nil)
+	mockServer.On("GenerateMerkleProofAt", 2, 1).Return([][32]byte{{1}}, []bool{false}, nil)
+
+	req, err := http.NewRequest("GET", "/proof/1?treeSize=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/proof/{index}", handler.ProofHandler)
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response map[string]interface{}
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["treeSize"] != float64(2) {
+		t.Errorf("Unexpected treeSize in response: %v", response["treeSize"])
+	}
+
+	mockServer.AssertExpectations(t)
+}
+
+func TestDownloadHandlerGzip(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+	router := mux.NewRouter()
+	router.HandleFunc("/download/{index}", handler.DownloadHandler)
+
+	mockServer.On("GetFileCount").Return(2)
+	mockServer.On("GetFileGzip", 0).Return([]byte("gzip stream"), true, nil)
+	mockServer.On("GetFileGzip", 1).Return(nil, false, nil)
+	mockServer.On("GetFileData", 1).Return([]byte("plain"), nil)
+
+	for _, tc := range []struct {
+		index    string
+		encoding string
+		body     string
+	}{
+		{"0", "gzip", "gzip stream"},
+		{"1", "", "plain"},
+	} {
+		req, _ := http.NewRequest("GET", "/download/"+tc.index, nil)
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Body.String() != tc.body {
+			t.Errorf("file %s: unexpected response %d %q", tc.index, rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Errorf("file %s: Content-Encoding got %q want %q", tc.index, got, tc.encoding)
+		}
+	}
+	mockServer.AssertExpectations(t)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=0.5": true,
+		"gzip;q=0":            false,
+		"*":                   true,
+		"br":                  false,
+		"*;q=0, gzip":         true,
+		"gzip;q=0, *":         false,
+		"GZIP":                true,
+	} {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
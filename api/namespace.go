@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/gorilla/mux"
+)
+
+// ListNamespacesHandler lists every namespace with its quota and usage, GET /admin/namespaces
+func (h *Handlers) ListNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(h.Namespaces.List())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreateNamespaceHandler creates a namespace from a JSON body with its name
+// and quota in bytes, POST /admin/namespaces
+func (h *Handlers) CreateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	var request server.NamespaceConfig
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.Namespaces.Create(request.Name, request.QuotaBytes)
+	if errors.Is(err, server.ErrNamespaceExists) {
+		writeJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, _ := h.Namespaces.Info(request.Name)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(info)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// NamespaceHandler describes one namespace, GET /admin/namespaces/{namespace}
+func (h *Handlers) NamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.Namespaces.Info(mux.Vars(r)["namespace"])
+	if !ok {
+		writeJSONError(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(info)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SetQuotaHandler changes the quota of a namespace from a JSON body with
+// quotaBytes, PUT /admin/namespaces/{namespace}
+func (h *Handlers) SetQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["namespace"]
+	var request server.NamespaceConfig
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.Namespaces.SetQuota(name, request.QuotaBytes)
+	if errors.Is(err, server.ErrNoNamespace) {
+		writeJSONError(w, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, _ := h.Namespaces.Info(name)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(info)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+func TestNamespaceRoutes(t *testing.T) {
+	namespaces, _ := server.NewNamespaces(context.Background(), "", func(context.Context, string) (*server.Server, error) {
+		return server.NewServer(), nil
+	})
+	global := server.NewServer()
+	router := NewRouter(global, RouterOptions{Namespaces: namespaces})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	upload := func(path string, data []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "file.txt")
+		part.Write(data)
+		writer.Close()
+		req, _ := http.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("POST", "/admin/namespaces", `{"name":"team-a","quotaBytes":10}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Create: got %v want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if rr := do("POST", "/admin/namespaces", `{"name":"team-a"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Create: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if rr := do("POST", "/admin/namespaces", `{"name":"Team A"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Create: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	if rr := upload("/ns/team-a/upload", []byte("hello")); rr.Code != http.StatusOK {
+		t.Fatalf("Upload: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if rr := upload("/ns/team-a/upload", []byte("over quota")); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Upload: got %v want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+	if rr := upload("/ns/team-b/upload", []byte("hello")); rr.Code != http.StatusNotFound {
+		t.Errorf("Upload: got %v want %v for an unknown namespace", rr.Code, http.StatusNotFound)
+	}
+	if global.GetFileCount() != 0 {
+		t.Errorf("Expected no files in the global tree, got %d", global.GetFileCount())
+	}
+	if rr := do("GET", "/ns/team-a/download/0", ""); rr.Body.String() != "hello" {
+		t.Errorf("Download: got %q want %q", rr.Body.String(), "hello")
+	}
+	if rr := do("GET", "/ns/team-a/proof/0", ""); rr.Code != http.StatusOK {
+		t.Errorf("Proof: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	if rr := do("PUT", "/admin/namespaces/team-a", `{"quotaBytes":100}`); rr.Code != http.StatusOK {
+		t.Errorf("SetQuota: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := do("PUT", "/admin/namespaces/team-b", `{"quotaBytes":100}`); rr.Code != http.StatusNotFound {
+		t.Errorf("SetQuota: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	var info server.NamespaceInfo
+	rr := do("GET", "/admin/namespaces/team-a", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if info.QuotaBytes != 100 || info.UsedBytes != 5 || info.Files != 1 {
+		t.Errorf("Unexpected namespace: %+v", info)
+	}
+}
@@ -1,18 +1,93 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/akhilesharora/go-merkle/internal/server"
 	"github.com/gorilla/mux"
 )
 
+// treeRoute is an endpoint served for the global tree and again under
+// /ns/{namespace} for every namespace
+type treeRoute struct {
+	path    string
+	method  string
+	scope   Scope
+	handler func(*Handlers, http.ResponseWriter, *http.Request)
+}
+
+var treeRoutes = []treeRoute{
+	{"/upload", "POST", ScopeUpload, (*Handlers).UploadHandler},
+	{"/download/{index}", "GET", ScopeRead, (*Handlers).DownloadHandler},
+	{"/proof/{index}", "GET", ScopeRead, (*Handlers).ProofHandler},
+	{"/tree/node", "GET", ScopeRead, (*Handlers).NodeHandler},
+	{"/tree/nodes", "GET", ScopeRead, (*Handlers).NodesHandler},
+	{"/root", "GET", ScopeRead, (*Handlers).RootHandler},
+	{"/info", "GET", ScopeRead, (*Handlers).InfoHandler},
+	{"/consistency", "GET", ScopeRead, (*Handlers).ConsistencyHandler},
+	{"/timestamp", "POST", ScopeUpload, (*Handlers).TimestampHandler},
+	{"/epoch", "GET", ScopeRead, (*Handlers).EpochHandler},
+	{"/audit/{index}", "POST", ScopeRead, (*Handlers).AuditHandler},
+}
+
+// RouterOptions configures what NewRouter serves beyond the global tree
+type RouterOptions struct {
+	// Namespaces are served under /ns/{namespace} and managed through the admin API when set
+	Namespaces *server.Namespaces
+	// Auth checks the credentials of every API request; nil leaves the API open
+	Auth *Authenticator
+	// AllowedOrigins may make cross-origin requests, or any origin with "*"; empty allows none
+	AllowedOrigins []string
+}
+
 func SetupRoutes(s server.ServerInterface) *mux.Router {
+	return NewRouter(s, RouterOptions{})
+}
+
+// NewRouter serves s as the global tree, along with the namespaces,
+// authentication and CORS policy set in opts
+func NewRouter(s server.ServerInterface, opts RouterOptions) *mux.Router {
 	r := mux.NewRouter()
-	h := &Handlers{Server: s}
+	h := &Handlers{Server: s, Namespaces: opts.Namespaces}
+	cors := CORS(opts.AllowedOrigins)
+	auth := opts.Auth
 
-	r.HandleFunc("/upload", CORSMiddleware(h.UploadHandler)).Methods("POST", "OPTIONS")
-	r.HandleFunc("/download/{index}", CORSMiddleware(h.DownloadHandler)).Methods("GET", "OPTIONS")
-	r.HandleFunc("/proof/{index}", CORSMiddleware(h.ProofHandler)).Methods("GET", "OPTIONS")
+	for _, route := range treeRoutes {
+		r.HandleFunc(route.path, cors(auth.Require(route.scope, h.bind(route.handler)))).Methods(route.method, "OPTIONS")
+	}
+	if opts.Namespaces != nil {
+		ns := r.PathPrefix("/ns/{namespace}").Subrouter()
+		for _, route := range treeRoutes {
+			ns.HandleFunc(route.path, cors(auth.RequireInNamespace(route.scope, h.inNamespace(route.handler)))).Methods(route.method, "OPTIONS")
+		}
+		r.HandleFunc("/admin/namespaces", auth.Require(ScopeAdmin, h.ListNamespacesHandler)).Methods("GET")
+		r.HandleFunc("/admin/namespaces", auth.Require(ScopeAdmin, h.CreateNamespaceHandler)).Methods("POST")
+		r.HandleFunc("/admin/namespaces/{namespace}", auth.Require(ScopeAdmin, h.NamespaceHandler)).Methods("GET")
+		r.HandleFunc("/admin/namespaces/{namespace}", auth.Require(ScopeAdmin, h.SetQuotaHandler)).Methods("PUT")
+	}
+	r.HandleFunc("/admin/scrub", auth.Require(ScopeAdmin, h.ScrubStatusHandler)).Methods("GET")
+	r.HandleFunc("/admin/scrub/{index}", auth.Require(ScopeAdmin, h.ScrubFileHandler)).Methods("POST")
+	r.HandleFunc("/metrics", auth.Require(ScopeRead, h.MetricsHandler)).Methods("GET")
 	r.PathPrefix("/").HandlerFunc(h.ServeUI)
 
 	return r
 }
+
+// bind turns a handler method into a handler for h
+func (h *Handlers) bind(handler func(*Handlers, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(h, w, r)
+	}
+}
+
+// inNamespace runs a handler method against the server of the namespace named in the path
+func (h *Handlers) inNamespace(handler func(*Handlers, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srv, ok := h.Namespaces.Get(mux.Vars(r)["namespace"])
+		if !ok {
+			writeJSONError(w, "Namespace not found", http.StatusNotFound)
+			return
+		}
+		handler(&Handlers{Server: srv}, w, r)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ScrubStatusHandler reports the integrity scrubber's progress and the quarantined files, GET /admin/scrub
+func (h *Handlers) ScrubStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(h.Server.ScrubStatus())
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ScrubFileHandler checks one file right away, POST /admin/scrub/{index}.
+// A quarantined file that has been repaired is released.
+func (h *Handlers) ScrubFileHandler(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil || index < 0 || index >= h.Server.GetFileCount() {
+		writeJSONError(w, "Invalid file index", http.StatusBadRequest)
+		return
+	}
+
+	intact, err := h.Server.ScrubFile(index)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"index":  index,
+		"intact": intact,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// MetricsHandler serves the scrubber's counters and storage sizes in the Prometheus text format, GET /metrics
+func (h *Handlers) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	status := h.Server.ScrubStatus()
+	lastPass := int64(0)
+	if !status.LastPassAt.IsZero() {
+		lastPass = status.LastPassAt.Unix()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP merkle_files Number of files in the tree.\n# TYPE merkle_files gauge\nmerkle_files %d\n", h.Server.GetFileCount())
+	fmt.Fprintf(w, "# HELP merkle_scrub_files_checked_total Files re-hashed by the scrubber.\n# TYPE merkle_scrub_files_checked_total counter\nmerkle_scrub_files_checked_total %d\n", status.FilesChecked)
+	fmt.Fprintf(w, "# HELP merkle_scrub_bytes_checked_total Bytes re-hashed by the scrubber.\n# TYPE merkle_scrub_bytes_checked_total counter\nmerkle_scrub_bytes_checked_total %d\n", status.BytesChecked)
+	fmt.Fprintf(w, "# HELP merkle_scrub_passes_total Completed scrubs over every file.\n# TYPE merkle_scrub_passes_total counter\nmerkle_scrub_passes_total %d\n", status.Passes)
+	fmt.Fprintf(w, "# HELP merkle_scrub_last_pass_timestamp_seconds End of the last completed scrub.\n# TYPE merkle_scrub_last_pass_timestamp_seconds gauge\nmerkle_scrub_last_pass_timestamp_seconds %d\n", lastPass)
+	compression := h.Server.CompressionStats()
+	fmt.Fprintf(w, "# HELP merkle_storage_bytes_uncompressed Size of the stored files before compression.\n# TYPE merkle_storage_bytes_uncompressed gauge\nmerkle_storage_bytes_uncompressed %d\n", compression.BytesUncompressed)
+	fmt.Fprintf(w, "# HELP merkle_storage_bytes_stored Bytes the stored files take after compression.\n# TYPE merkle_storage_bytes_stored gauge\nmerkle_storage_bytes_stored %d\n", compression.BytesStored)
+	fmt.Fprintf(w, "# HELP merkle_storage_bytes_saved Bytes saved by compressing stored files.\n# TYPE merkle_storage_bytes_saved gauge\nmerkle_storage_bytes_saved %d\n", compression.BytesSaved())
+	fmt.Fprintf(w, "# HELP merkle_scrub_quarantined_files Files whose stored content does not match their leaf hash.\n# TYPE merkle_scrub_quarantined_files gauge\nmerkle_scrub_quarantined_files %d\n", len(status.Quarantined))
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/gorilla/mux"
+)
+
+func TestScrubHandlers(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/scrub", handler.ScrubStatusHandler)
+	router.HandleFunc("/admin/scrub/{index}", handler.ScrubFileHandler)
+	router.HandleFunc("/metrics", handler.MetricsHandler)
+
+	status := server.ScrubStatus{FilesChecked: 12, Passes: 3, Quarantined: []server.QuarantinedFile{{Index: 2, Reason: "bad"}}}
+	mockServer.On("ScrubStatus").Return(status)
+	mockServer.On("GetFileCount").Return(4)
+	mockServer.On("ScrubFile", 2).Return(false, nil)
+	mockServer.On("CompressionStats").Return(storage.CompressionStats{Blobs: 4, BytesUncompressed: 1000, BytesStored: 300})
+
+	req, _ := http.NewRequest("GET", "/admin/scrub", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response server.ScrubStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.FilesChecked != 12 || len(response.Quarantined) != 1 || response.Quarantined[0].Index != 2 {
+		t.Errorf("Unexpected scrub status: %+v", response)
+	}
+
+	req, _ = http.NewRequest("POST", "/admin/scrub/2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"intact":false`) {
+		t.Errorf("Unexpected scrub response: %d %s", rr.Code, rr.Body.String())
+	}
+	req, _ = http.NewRequest("POST", "/admin/scrub/9", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	for _, line := range []string{"merkle_files 4", "merkle_scrub_files_checked_total 12", "merkle_scrub_passes_total 3", "merkle_scrub_quarantined_files 1", "merkle_storage_bytes_saved 700"} {
+		if !strings.Contains(rr.Body.String(), line+"\n") {
+			t.Errorf("Metrics missing %q:\n%s", line, rr.Body.String())
+		}
+	}
+	mockServer.AssertExpectations(t)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+// timestampRequest is the body of a /timestamp request. Only the document
+// hash is submitted, never its content.
+type timestampRequest struct {
+	Hash string `json:"hash"`
+}
+
+// TimestampHandler includes a SHA-256 document hash in the next timestamp
+// epoch and responds with a receipt once the epoch is sealed, POST /timestamp
+func (h *Handlers) TimestampHandler(w http.ResponseWriter, r *http.Request) {
+	var request timestampRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid timestamp request", http.StatusBadRequest)
+		return
+	}
+	hash, err := hex.DecodeString(request.Hash)
+	if err != nil || len(hash) != 32 {
+		writeJSONError(w, "Hash must be a hex-encoded SHA-256 hash", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := h.Server.Timestamp(r.Context(), [32]byte(hash))
+	if errors.Is(err, server.ErrReadOnly) {
+		writeJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, server.ErrQuotaExceeded) {
+		writeJSONError(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(receipt)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
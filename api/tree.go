@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxNodeBatch is the largest number of node hashes served by one /tree/nodes request
+const MaxNodeBatch = 256
+
+// nodeResponse is the body of a /tree/node response
+type nodeResponse struct {
+	TreeSize int    `json:"treeSize"`
+	Level    int    `json:"level"`
+	Index    int    `json:"index"`
+	Hash     string `json:"hash"`
+}
+
+// nodesResponse is the body of a /tree/nodes response
+type nodesResponse struct {
+	TreeSize int      `json:"treeSize"`
+	Level    int      `json:"level"`
+	Indices  []int    `json:"indices"`
+	Hashes   []string `json:"hashes"`
+}
+
+// NodeHandler serves the hash of a single tree node, GET /tree/node?level=&index=&treeSize=
+func (h *Handlers) NodeHandler(w http.ResponseWriter, r *http.Request) {
+	treeSize, level, ok := h.parseNodeQuery(w, r)
+	if !ok {
+		return
+	}
+	index, err := strconv.Atoi(r.URL.Query().Get("index"))
+	if err != nil {
+		writeJSONError(w, "Invalid node index", http.StatusBadRequest)
+		return
+	}
+
+	hashes, err := h.Server.GetNodeHashes(treeSize, level, []int{index})
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := nodeResponse{
+		TreeSize: treeSize,
+		Level:    level,
+		Index:    index,
+		Hash:     hex.EncodeToString(hashes[0][:]),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// NodesHandler serves the hashes of up to MaxNodeBatch nodes on one level,
+// GET /tree/nodes?level=&indices=0,1,2&treeSize=
+func (h *Handlers) NodesHandler(w http.ResponseWriter, r *http.Request) {
+	treeSize, level, ok := h.parseNodeQuery(w, r)
+	if !ok {
+		return
+	}
+
+	var indices []int
+	if raw := r.URL.Query().Get("indices"); raw != "" {
+		parts := strings.Split(raw, ",")
+		if len(parts) > MaxNodeBatch {
+			writeJSONError(w, "Too many node indices", http.StatusBadRequest)
+			return
+		}
+		for _, part := range parts {
+			index, err := strconv.Atoi(part)
+			if err != nil {
+				writeJSONError(w, "Invalid node index", http.StatusBadRequest)
+				return
+			}
+			indices = append(indices, index)
+		}
+	}
+
+	response := nodesResponse{TreeSize: treeSize, Level: level, Indices: indices, Hashes: []string{}}
+	if len(indices) > 0 {
+		hashes, err := h.Server.GetNodeHashes(treeSize, level, indices)
+		if err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, hash := range hashes {
+			response.Hashes = append(response.Hashes, hex.EncodeToString(hash[:]))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseNodeQuery reads the level and tree size of a node request. The tree
+// size defaults to the current number of files.
+func (h *Handlers) parseNodeQuery(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	query := r.URL.Query()
+
+	treeSize := h.Server.GetFileCount()
+	if raw := query.Get("treeSize"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > treeSize {
+			writeJSONError(w, "Invalid tree size", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		treeSize = size
+	}
+
+	level, err := strconv.Atoi(query.Get("level"))
+	if err != nil || level < 0 {
+		writeJSONError(w, "Invalid level", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return treeSize, level, true
+}
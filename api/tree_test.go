@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNodeHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	mockServer.On("GetFileCount").Return(4)
+	mockServer.On("GetNodeHashes", 3, 1, []int{1}).Return([][32]byte{{0xab}}, nil)
+
+	req, err := http.NewRequest("GET", "/tree/node?level=1&index=1&treeSize=3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.NodeHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response nodeResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if !strings.HasPrefix(response.Hash, "ab00") || response.TreeSize != 3 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	mockServer.AssertExpectations(t)
+}
+
+func TestNodesHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	mockServer.On("GetFileCount").Return(4)
+	mockServer.On("GetNodeHashes", 4, 0, []int{0, 2}).Return([][32]byte{{1}, {2}}, nil)
+
+	req, err := http.NewRequest("GET", "/tree/nodes?level=0&indices=0,2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler.NodesHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response nodesResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Hashes) != 2 || response.TreeSize != 4 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	mockServer.AssertExpectations(t)
+}
+
+func TestNodesHandlerLimits(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+	mockServer.On("GetFileCount").Return(4)
+
+	indices := make([]string, MaxNodeBatch+1)
+	for i := range indices {
+		indices[i] = fmt.Sprint(i)
+	}
+
+	for _, query := range []string{
+		"level=0&indices=" + strings.Join(indices, ","),
+		"level=0&indices=0&treeSize=5",
+		"level=-1&indices=0",
+		"level=0&indices=x",
+	} {
+		req, _ := http.NewRequest("GET", "/tree/nodes?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.NodesHandler(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// consistencyResponse is the body of a /consistency response
+type consistencyResponse struct {
+	From  int      `json:"from"`
+	To    int      `json:"to"`
+	Proof []string `json:"proof"`
+}
+
+// infoResponse is the body of an /info response
+type infoResponse struct {
+	TreeMode string `json:"treeMode"`
+}
+
+// InfoHandler serves what clients need to know about the tree before using
+// it, GET /info
+func (h *Handlers) InfoHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(infoResponse{TreeMode: h.Server.TreeMode()})
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RootHandler serves the signed tree head of the current tree, GET /root
+func (h *Handlers) RootHandler(w http.ResponseWriter, r *http.Request) {
+	sth, err := h.Server.GetSignedTreeHead()
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(sth)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ConsistencyHandler serves a proof that the tree of size from is a prefix of
+// the tree of size to, GET /consistency?from=&to=. The target size defaults to
+// the current tree.
+func (h *Handlers) ConsistencyHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	count := h.Server.GetFileCount()
+
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil || from < 1 || from > count {
+		writeJSONError(w, "Invalid from size", http.StatusBadRequest)
+		return
+	}
+	to := count
+	if raw := query.Get("to"); raw != "" {
+		to, err = strconv.Atoi(raw)
+		if err != nil || to < from || to > count {
+			writeJSONError(w, "Invalid to size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	proof, err := h.Server.GetConsistencyProof(from, to)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := consistencyResponse{From: from, To: to, Proof: make([]string, len(proof))}
+	for i, hash := range proof {
+		response.Proof[i] = hex.EncodeToString(hash[:])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
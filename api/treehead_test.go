@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+func TestRootHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	sth := &treehead.SignedTreeHead{TreeSize: 3, RootHash: "ab", Timestamp: 1, Signature: []byte{1}}
+	mockServer.On("GetSignedTreeHead").Return(sth, nil)
+
+	req, _ := http.NewRequest("GET", "/root", nil)
+	rr := httptest.NewRecorder()
+	handler.RootHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response treehead.SignedTreeHead
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.TreeSize != 3 || response.RootHash != "ab" {
+		t.Errorf("Unexpected tree head in response: %+v", response)
+	}
+
+	mockServer.AssertExpectations(t)
+}
+
+func TestInfoHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+	mockServer.On("TreeMode").Return(server.TreeModeMMR)
+
+	req, _ := http.NewRequest("GET", "/info", nil)
+	rr := httptest.NewRecorder()
+	handler.InfoHandler(rr, req)
+
+	var response infoResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.TreeMode != server.TreeModeMMR {
+		t.Errorf("Expected tree mode %s, got %q", server.TreeModeMMR, response.TreeMode)
+	}
+
+	mockServer.AssertExpectations(t)
+}
+
+func TestConsistencyHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	mockServer.On("GetFileCount").Return(5)
+	mockServer.On("GetConsistencyProof", 2, 5).Return([][32]byte{{1}, {2}}, nil)
+
+	req, _ := http.NewRequest("GET", "/consistency?from=2", nil)
+	rr := httptest.NewRecorder()
+	handler.ConsistencyHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response consistencyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.To != 5 || len(response.Proof) != 2 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	for _, query := range []string{"from=0", "from=6", "from=3&to=2"} {
+		req, _ := http.NewRequest("GET", "/consistency?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.ConsistencyHandler(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUploadHandlerReadOnly(t *testing.T) {
+	srv := server.NewServer()
+	srv.ReadOnly = true
+	handler := &Handlers{Server: srv}
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "testfile.txt")
+	_, _ = part.Write([]byte("file content"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.UploadHandler(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestUploadHandlerReturnsPromise(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	srv := server.NewServer()
+	srv.SigningKey = private
+	handler := &Handlers{Server: srv}
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "testfile.txt")
+	_, _ = part.Write([]byte("file content"))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.UploadHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response struct {
+		FileIndex int                        `json:"fileIndex"`
+		Promise   *treehead.InclusionPromise `json:"promise"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Promise == nil {
+		t.Fatal("Expected an inclusion promise in the response")
+	}
+	if err := response.Promise.Verify(public); err != nil {
+		t.Errorf("Promise doesn't verify: %v", err)
+	}
+}
+
+func TestTimestampHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	hash := [32]byte{1}
+	receipt := &treehead.TimestampReceipt{Hash: hex.EncodeToString(hash[:]), Index: 2}
+	mockServer.On("Timestamp", hash).Return(receipt, nil)
+
+	body := `{"hash":"` + hex.EncodeToString(hash[:]) + `"}`
+	req, _ := http.NewRequest("POST", "/timestamp", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.TimestampHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response treehead.TimestampReceipt
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Index != 2 || response.Hash != receipt.Hash {
+		t.Errorf("Unexpected receipt in response: %+v", response)
+	}
+
+	for _, body := range []string{`{"hash":"abcd"}`, `{"hash":"zz"}`, `not json`} {
+		req, _ := http.NewRequest("POST", "/timestamp", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.TimestampHandler(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+	mockServer.AssertExpectations(t)
+}
+
+func TestEpochHandler(t *testing.T) {
+	mockServer := new(MockServer)
+	handler := &Handlers{Server: mockServer}
+
+	mockServer.On("WaitForEpoch", 3).Return(&server.Epoch{Number: 3, TreeSize: 10}, nil)
+
+	req, _ := http.NewRequest("GET", "/epoch?wait=3&timeout=5s", nil)
+	rr := httptest.NewRecorder()
+	handler.EpochHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response server.Epoch
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Number != 3 || response.TreeSize != 10 {
+		t.Errorf("Unexpected epoch in response: %+v", response)
+	}
+
+	for _, query := range []string{"wait=x", "wait=-1", "wait=1&timeout=soon"} {
+		req, _ := http.NewRequest("GET", "/epoch?"+query, nil)
+		rr := httptest.NewRecorder()
+		handler.EpochHandler(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+	mockServer.AssertExpectations(t)
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/akhilesharora/go-merkle/internal/client"
 	"github.com/akhilesharora/go-merkle/pkg/config"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 )
 
+const usage = "Expected 'upload', 'download', 'diff', 'monitor', 'check-promises', 'timestamp', 'verify-timestamp' or 'audit' subcommands"
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -18,15 +24,51 @@ func main() {
 	}
 
 	c := client.NewClient(cfg.ServerAddress())
+	c.AuthToken = cfg.APIToken
 
 	uploadCmd := flag.NewFlagSet("upload", flag.ExitOnError)
 	uploadFiles := uploadCmd.String("files", "", "Comma-separated list of files to upload")
+	uploadWait := uploadCmd.Duration("wait", 0, "Wait up to this long for the server to seal the uploads into a tree head")
+	uploadKey := uploadCmd.String("encryption-key", "", "Encrypt files before upload with the AES-256 key in this file, created if missing")
+	uploadCommit := uploadCmd.Bool("commit", false, "Add a plaintext commitment to encrypted files")
 
 	downloadCmd := flag.NewFlagSet("download", flag.ExitOnError)
 	downloadIndex := downloadCmd.Int("index", 0, "Index of file to download")
+	downloadKey := downloadCmd.String("encryption-key", "", "Decrypt the file with the AES-256 key in this file")
+
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffFiles := diffCmd.String("files", "", "Comma-separated list of local files to compare with the server")
+
+	monitorCmd := flag.NewFlagSet("monitor", flag.ExitOnError)
+	monitorKey := monitorCmd.String("key", "", "Hex Ed25519 public key of the server's tree heads")
+	monitorState := monitorCmd.String("state", "trusted_head.json", "File holding the latest trusted tree head")
+	monitorInterval := monitorCmd.Duration("interval", time.Minute, "How often to poll the server")
+	monitorWebhook := monitorCmd.String("webhook", "", "URL to POST alerts to")
+	monitorOnce := monitorCmd.Bool("once", false, "Check the current tree head once and exit")
+	monitorWitnesses := monitorCmd.String("witnesses", "", "Comma-separated hex public keys of known witnesses")
+	monitorQuorum := monitorCmd.Int("quorum", 0, "Number of known witnesses that must cosign each tree head")
+
+	checkPromisesCmd := flag.NewFlagSet("check-promises", flag.ExitOnError)
+	checkPromisesKey := checkPromisesCmd.String("key", "", "Hex Ed25519 public key of the server")
+	checkPromisesFile := checkPromisesCmd.String("promises", client.PromisesFile, "File holding the inclusion promises recorded on upload")
+
+	timestampCmd := flag.NewFlagSet("timestamp", flag.ExitOnError)
+	timestampFile := timestampCmd.String("file", "", "Document to timestamp; only its hash is sent")
+	timestampReceipt := timestampCmd.String("receipt", "", "File to write the receipt to (default <file>.receipt.json)")
+
+	verifyTimestampCmd := flag.NewFlagSet("verify-timestamp", flag.ExitOnError)
+	verifyTimestampFile := verifyTimestampCmd.String("file", "", "Timestamped document")
+	verifyTimestampReceipt := verifyTimestampCmd.String("receipt", "", "Receipt file (default <file>.receipt.json)")
+	verifyTimestampKey := verifyTimestampCmd.String("key", "", "Hex Ed25519 public key of the server")
+
+	auditCmd := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditRecords := auditCmd.String("records", client.ChunkRootsFile, "File holding the chunk roots recorded on upload")
+	auditFiles := auditCmd.Int("files", 10, "Number of randomly chosen files to audit")
+	auditChunks := auditCmd.Int("chunks", 16, "Number of randomly chosen chunks to check per file")
+	auditLoss := auditCmd.Float64("loss", 0.01, "Fraction of lost chunks the audit should detect")
 
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'upload' or 'download' subcommands")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 
@@ -37,6 +79,14 @@ func main() {
 			return
 		}
 		files := strings.Split(*uploadFiles, ",")
+		c.EpochWait = *uploadWait
+		if *uploadKey != "" {
+			c.EncryptionKey, err = client.LoadOrCreateEncryptionKey(*uploadKey)
+			if err != nil {
+				log.Fatalf("Failed to load encryption key: %v", err)
+			}
+			c.CommitPlaintext = *uploadCommit
+		}
 		rootHash, err := c.UploadFiles(files)
 		if err != nil {
 			log.Fatalf("Failed to upload files: %v", err)
@@ -47,13 +97,176 @@ func main() {
 		if err != nil {
 			return
 		}
-		fileData, err := c.DownloadAndVerifyFile(*downloadIndex)
+		var fileData []byte
+		if *downloadKey != "" {
+			c.EncryptionKey, err = client.LoadEncryptionKey(*downloadKey)
+			if err != nil {
+				log.Fatalf("Failed to load encryption key: %v", err)
+			}
+			fileData, err = c.DownloadAndDecryptFile(*downloadIndex)
+		} else {
+			fileData, err = c.DownloadAndVerifyFile(*downloadIndex)
+		}
 		if err != nil {
 			log.Fatalf("Failed to download and verify file: %v", err)
 		}
 		fmt.Printf("Download successful. File data: %s\n", string(fileData))
+	case "diff":
+		err := diffCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		ranges, err := c.DiffWithServer(strings.Split(*diffFiles, ","))
+		if err != nil {
+			log.Fatalf("Failed to diff with server: %v", err)
+		}
+		if len(ranges) == 0 {
+			fmt.Println("Local files match the server")
+			return
+		}
+		for _, r := range ranges {
+			fmt.Printf("Files %d to %d differ\n", r.Start, r.End-1)
+		}
+	case "monitor":
+		err := monitorCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		if cfg.TreeMode == client.TreeModeMMR {
+			log.Fatalf("Monitor is %v", client.ErrUnsupportedTreeMode)
+		}
+		key, err := treehead.ParsePublicKey(*monitorKey)
+		if err != nil {
+			log.Fatalf("Invalid -key: %v", err)
+		}
+		monitor := client.NewMonitor(c, key, *monitorState)
+		monitor.WebhookURL = *monitorWebhook
+		monitor.Quorum = *monitorQuorum
+		if *monitorWitnesses != "" {
+			for _, encoded := range strings.Split(*monitorWitnesses, ",") {
+				witnessKey, err := treehead.ParsePublicKey(encoded)
+				if err != nil {
+					log.Fatalf("Invalid -witnesses: %v", err)
+				}
+				monitor.Witnesses = append(monitor.Witnesses, witnessKey)
+			}
+		}
+		if *monitorOnce {
+			sth, err := monitor.Check()
+			if errors.Is(err, client.ErrMisbehavior) {
+				os.Exit(2)
+			}
+			if err != nil {
+				log.Fatalf("Monitor check failed: %v", err)
+			}
+			fmt.Printf("Trusted tree head: size %d, root %s\n", sth.TreeSize, sth.RootHash)
+			return
+		}
+		log.Println(monitor.Run(*monitorInterval))
+		os.Exit(2)
+	case "check-promises":
+		err := checkPromisesCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		key, err := treehead.ParsePublicKey(*checkPromisesKey)
+		if err != nil {
+			log.Fatalf("Invalid -key: %v", err)
+		}
+		promises, err := client.LoadPromises(*checkPromisesFile)
+		if err != nil {
+			log.Fatalf("Failed to load promises: %v", err)
+		}
+		broken := false
+		for _, promise := range promises {
+			err := c.CheckInclusionPromise(promise, key)
+			switch {
+			case err == nil:
+				fmt.Printf("File %d: included\n", promise.Index)
+			case errors.Is(err, client.ErrPromisePending):
+				fmt.Printf("File %d: pending until %s\n", promise.Index, promise.Deadline().Format(time.RFC3339))
+			default:
+				fmt.Printf("File %d: %v\n", promise.Index, err)
+				broken = true
+			}
+		}
+		if broken {
+			os.Exit(1)
+		}
+	case "timestamp":
+		err := timestampCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		data, err := os.ReadFile(*timestampFile)
+		if err != nil {
+			log.Fatalf("Failed to read document: %v", err)
+		}
+		receipt, err := c.Timestamp(data)
+		if err != nil {
+			log.Fatalf("Failed to timestamp document: %v", err)
+		}
+		path := receiptPath(*timestampReceipt, *timestampFile)
+		if err := client.SaveReceipt(path, receipt); err != nil {
+			log.Fatalf("Failed to save receipt: %v", err)
+		}
+		fmt.Printf("Timestamped at %s, receipt written to %s\n", receipt.TreeHead.Time().Format(time.RFC3339), path)
+	case "verify-timestamp":
+		err := verifyTimestampCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		key, err := treehead.ParsePublicKey(*verifyTimestampKey)
+		if err != nil {
+			log.Fatalf("Invalid -key: %v", err)
+		}
+		data, err := os.ReadFile(*verifyTimestampFile)
+		if err != nil {
+			log.Fatalf("Failed to read document: %v", err)
+		}
+		receipt, err := client.LoadReceipt(receiptPath(*verifyTimestampReceipt, *verifyTimestampFile))
+		if err != nil {
+			log.Fatalf("Failed to load receipt: %v", err)
+		}
+		at, err := client.VerifyTimestamp(receipt, data, key)
+		if err != nil {
+			log.Fatalf("Timestamp verification failed: %v", err)
+		}
+		fmt.Printf("Document existed at %s\n", at.Format(time.RFC3339))
+	case "audit":
+		err := auditCmd.Parse(os.Args[2:])
+		if err != nil {
+			return
+		}
+		records, err := client.LoadChunkRecords(*auditRecords)
+		if err != nil {
+			log.Fatalf("Failed to load chunk records: %v", err)
+		}
+		rand.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+		failed := false
+		for _, record := range records[:min(*auditFiles, len(records))] {
+			checked, err := c.AuditFile(record, *auditChunks)
+			if err != nil {
+				fmt.Printf("File %d: %v\n", record.FileIndex, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("File %d: %d of %d chunks verified, %.2f%% chance of detecting %.2f%% chunk loss\n",
+				record.FileIndex, checked, record.Chunks, 100*client.DetectionProbability(checked, *auditLoss), 100**auditLoss)
+		}
+		if failed {
+			os.Exit(1)
+		}
 	default:
-		fmt.Println("Expected 'upload' or 'download' subcommands")
+		fmt.Println(usage)
 		os.Exit(1)
 	}
 }
+
+// receiptPath returns the receipt file for a document, defaulting to <document>.receipt.json
+func receiptPath(flagValue, document string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return document + ".receipt.json"
+}
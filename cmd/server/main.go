@@ -2,17 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/replication"
 	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/internal/witness"
 	"github.com/akhilesharora/go-merkle/pkg/config"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 )
 
 func main() {
@@ -21,8 +32,86 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	srv := server.NewServer()
-	router := api.SetupRoutes(srv)
+	if len(os.Args) > 1 && os.Args[1] == "scrub" {
+		scrubStore(cfg)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		rotateKeys(cfg)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "issue-token" {
+		issueToken(cfg, os.Args[2:])
+		return
+	}
+
+	srv, err := server.NewServerWithTreeMode(cfg.TreeMode)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	if cfg.EncryptionKeyring != "" {
+		// Held until the server exits so rotate-keys can't run underneath it
+		unlock, err := storage.LockKeyring(cfg.EncryptionKeyring)
+		if err != nil {
+			log.Fatalf("Failed to lock keyring: %v", err)
+		}
+		defer unlock()
+	}
+	if cfg.DataDir != "" {
+		if err := restoreServer(srv, cfg, ""); err != nil {
+			log.Fatalf("Failed to restore server state: %v", err)
+		}
+	}
+	srv.SigningKey = loadSigningKey(cfg)
+	srv.MaxMergeDelay = cfg.MaxMergeDelay
+
+	ctx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if cfg.LeaderURL != "" {
+		startReplication(ctx, srv, cfg)
+	}
+	if !srv.ReadOnly {
+		go srv.RunEpochs(ctx, epochInterval(srv, cfg))
+	}
+	// Files only kept in memory have nothing to rot, so the scrubber needs a store
+	if cfg.ScrubRate > 0 && srv.Store != nil {
+		go srv.RunScrubber(ctx, cfg.ScrubRate)
+	}
+	if cfg.DataDir != "" && cfg.SnapshotInterval > 0 {
+		go srv.RunSnapshots(ctx, cfg.SnapshotInterval)
+	}
+	if len(cfg.WitnessURLs) > 0 {
+		if cfg.TreeMode == server.TreeModeMMR {
+			log.Fatalf("Invalid configuration: WITNESS_URLS can't be used with TREE_MODE=mmr, witnesses need consistency proofs")
+		}
+		collector := witness.NewCollector(srv, cfg.WitnessURLs)
+		go collector.Run(ctx, cfg.WitnessInterval)
+	}
+
+	var namespaces *server.Namespaces
+	if cfg.LeaderURL == "" {
+		namespaces, err = server.NewNamespaces(ctx, namespacesPath(cfg), func(ctx context.Context, name string) (*server.Server, error) {
+			return openNamespace(ctx, cfg, name)
+		})
+		if err != nil {
+			log.Fatalf("Failed to open namespaces: %v", err)
+		}
+	}
+
+	auth, err := api.NewAuthenticator(cfg.APIKeys, cfg.TokenSecret)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if auth == nil {
+		log.Println("No API_KEYS or TOKEN_SECRET configured, the API is open to anyone")
+	}
+
+	router := api.NewRouter(srv, api.RouterOptions{
+		Namespaces:     namespaces,
+		Auth:           auth,
+		AllowedOrigins: cfg.CORSOrigins,
+	})
 
 	httpServer := &http.Server{
 		Addr:    cfg.ServerAddress(),
@@ -43,12 +132,328 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	stopBackground()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	servers := []*server.Server{srv}
+	if namespaces != nil {
+		servers = append(servers, namespaces.Servers()...)
+	}
+	for _, s := range servers {
+		s.Seal()
+		if err := s.SaveSnapshot(); err != nil {
+			log.Printf("Failed to save tree snapshot: %v", err)
+		}
+	}
+
 	log.Println("Server exiting")
 }
+
+// restoreServer loads stored files and the tree snapshot from the data
+// directory, or from its sub directory for a namespace
+func restoreServer(srv *server.Server, cfg *config.Config, sub string) error {
+	verify, err := merkle.ParseVerifyMode(cfg.SnapshotVerify)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	store, err := openStore(cfg, sub)
+	if err != nil {
+		return fmt.Errorf("opening file store: %w", err)
+	}
+
+	start := time.Now()
+	if err := srv.Restore(store, filepath.Join(cfg.DataDir, sub, "tree.snapshot"), verify); err != nil {
+		return err
+	}
+	log.Printf("Restored %d files in %s", srv.GetFileCount(), time.Since(start))
+	if stats := srv.CompressionStats(); stats.Blobs > 0 {
+		log.Printf("Compression saves %d of %d bytes", stats.BytesSaved(), stats.BytesUncompressed)
+	}
+	return nil
+}
+
+// namespacesPath is where the list of namespaces is kept; without a data
+// directory namespaces only live in memory
+func namespacesPath(cfg *config.Config) string {
+	if cfg.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.DataDir, "namespaces.json")
+}
+
+// namespaceDir is the sub directory of the data directory, and of every
+// erasure directory, that holds a namespace's files
+func namespaceDir(name string) string {
+	return filepath.Join("ns", name)
+}
+
+// openNamespace creates the server of a namespace with its own tree, files,
+// snapshot and signing key, configured like the global server
+func openNamespace(ctx context.Context, cfg *config.Config, name string) (*server.Server, error) {
+	srv, err := server.NewServerWithTreeMode(cfg.TreeMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DataDir == "" {
+		_, srv.SigningKey, err = ed25519.GenerateKey(nil)
+	} else {
+		if err = restoreServer(srv, cfg, namespaceDir(name)); err != nil {
+			return nil, err
+		}
+		srv.SigningKey, err = treehead.LoadOrCreateKey(filepath.Join(cfg.DataDir, namespaceDir(name), "signing.key"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading signing key: %w", err)
+	}
+	log.Printf("Namespace %s tree head public key: %s", name, hex.EncodeToString(srv.SigningKey.Public().(ed25519.PublicKey)))
+
+	srv.MaxMergeDelay = cfg.MaxMergeDelay
+	go srv.RunEpochs(ctx, epochInterval(srv, cfg))
+	if cfg.ScrubRate > 0 && srv.Store != nil {
+		go srv.RunScrubber(ctx, cfg.ScrubRate)
+	}
+	if cfg.DataDir != "" && cfg.SnapshotInterval > 0 {
+		go srv.RunSnapshots(ctx, cfg.SnapshotInterval)
+	}
+	return srv, nil
+}
+
+// openStore opens the erasure-coded store when ERASURE_DIRS is set and the
+// plain store in the data directory otherwise, encrypted when
+// ENCRYPTION_KEYRING is set. Files are compressed before they are encrypted.
+// A namespace's store is kept in the sub directory sub.
+func openStore(cfg *config.Config, sub string) (storage.Store, error) {
+	store, err := openPlainStore(cfg, sub)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EncryptionKeyring != "" {
+		keyring, err := storage.LoadOrCreateKeyring(cfg.EncryptionKeyring)
+		if err != nil {
+			return nil, err
+		}
+		store, err = storage.NewEncryptedStore(store, keyring, filepath.Join(cfg.DataDir, sub, "keys"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch cfg.Compression {
+	case "none":
+		return store, nil
+	case "gzip":
+		return storage.NewCompressedStore(store, filepath.Join(cfg.DataDir, sub, "compressed.sizes"))
+	default:
+		return nil, fmt.Errorf("unknown COMPRESSION %q", cfg.Compression)
+	}
+}
+
+// openPlainStore opens the store that holds files, or their ciphertext when they are encrypted
+func openPlainStore(cfg *config.Config, sub string) (storage.Store, error) {
+	if len(cfg.ErasureDirs) > 0 {
+		return openErasureStore(cfg, sub)
+	}
+	return storage.NewDiskStore(filepath.Join(cfg.DataDir, sub, "files"))
+}
+
+func openErasureStore(cfg *config.Config, sub string) (*storage.ErasureStore, error) {
+	dirs := make([]string, len(cfg.ErasureDirs))
+	for i, dir := range cfg.ErasureDirs {
+		dirs[i] = filepath.Join(dir, sub)
+	}
+	return storage.NewErasureStore(dirs, cfg.ErasureDataShards, cfg.ErasureParityShards)
+}
+
+// storeDirs lists the sub directory of the global store and of every
+// namespace, for commands that work on all stores
+func storeDirs(cfg *config.Config) []string {
+	configs, err := server.LoadNamespaceConfigs(namespacesPath(cfg))
+	if err != nil {
+		log.Fatalf("Failed to load namespaces: %v", err)
+	}
+	subs := []string{""}
+	for _, namespace := range configs {
+		subs = append(subs, namespaceDir(namespace.Name))
+	}
+	return subs
+}
+
+// rotateKeys adds a new key to the keyring, re-wraps every file's data key
+// in every namespace with it and then removes the old keys. Stored files are
+// not rewritten. If it fails midway the old keys are kept, so it can simply
+// be run again.
+func rotateKeys(cfg *config.Config) {
+	if cfg.EncryptionKeyring == "" || cfg.DataDir == "" {
+		log.Fatal("Nothing to rotate: ENCRYPTION_KEYRING and DATA_DIR must be set")
+	}
+	unlock, err := storage.LockKeyring(cfg.EncryptionKeyring)
+	if errors.Is(err, storage.ErrKeyringLocked) {
+		log.Fatal("The keyring is in use: stop the server before rotating keys")
+	}
+	if err != nil {
+		log.Fatalf("Failed to lock keyring: %v", err)
+	}
+	defer unlock()
+
+	keyring, err := storage.LoadOrCreateKeyring(cfg.EncryptionKeyring)
+	if err != nil {
+		log.Fatalf("Failed to load keyring: %v", err)
+	}
+	var stores []*storage.EncryptedStore
+	for _, sub := range storeDirs(cfg) {
+		plain, err := openPlainStore(cfg, sub)
+		if err != nil {
+			log.Fatalf("Failed to open file store: %v", err)
+		}
+		store, err := storage.NewEncryptedStore(plain, keyring, filepath.Join(cfg.DataDir, sub, "keys"))
+		if err != nil {
+			log.Fatalf("Failed to open file store: %v", err)
+		}
+		stores = append(stores, store)
+	}
+
+	id, err := keyring.Rotate()
+	if err != nil {
+		log.Fatalf("Failed to add a key: %v", err)
+	}
+	rewrapped := 0
+	for _, store := range stores {
+		n, err := store.Rewrap()
+		if err != nil {
+			log.Fatalf("Failed to re-wrap data keys: %v", err)
+		}
+		rewrapped += n
+	}
+	if err := keyring.Retire(); err != nil {
+		log.Fatalf("Failed to remove old keys: %v", err)
+	}
+	log.Printf("Rotated to key %s: re-wrapped %d data keys", id, rewrapped)
+}
+
+// issueToken prints a bearer token signed with TOKEN_SECRET
+func issueToken(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("issue-token", flag.ExitOnError)
+	subject := flags.String("subject", "", "Who the token is issued to")
+	scopes := flags.String("scopes", "read", "Comma-separated scopes: upload, read and admin")
+	ttl := flags.Duration("ttl", 24*time.Hour, "How long the token is valid; 0 never expires")
+	namespace := flags.String("namespace", "", "Restrict the token to the routes of this namespace")
+	flags.Parse(args)
+
+	if cfg.TokenSecret == "" {
+		log.Fatal("Cannot issue tokens: TOKEN_SECRET is not set")
+	}
+	parsed, err := api.ParseScopes(strings.Split(*scopes, ","))
+	if err != nil {
+		log.Fatalf("Invalid scopes: %v", err)
+	}
+	claims := api.TokenClaims{Subject: *subject, Scopes: parsed, Namespace: *namespace}
+	if *ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(*ttl).Unix()
+	}
+	token, err := api.IssueToken(cfg.TokenSecret, claims)
+	if err != nil {
+		log.Fatalf("Failed to issue token: %v", err)
+	}
+	fmt.Println(token)
+}
+
+// scrubStore checks every erasure-coded file of every namespace and rewrites
+// missing or corrupt shards, exiting with code 1 if any file can no longer be
+// reconstructed
+func scrubStore(cfg *config.Config) {
+	if len(cfg.ErasureDirs) == 0 {
+		log.Fatal("Nothing to scrub: ERASURE_DIRS is not set")
+	}
+
+	files, repaired, lost := 0, 0, 0
+	for _, sub := range storeDirs(cfg) {
+		store, err := openErasureStore(cfg, sub)
+		if err != nil {
+			log.Fatalf("Failed to open file store: %v", err)
+		}
+		for i := 0; i < store.Len(); i++ {
+			n, err := store.Scrub(i)
+			if err != nil {
+				log.Printf("File %d in %q: %v", i, sub, err)
+				lost++
+				continue
+			}
+			if n > 0 {
+				log.Printf("File %d in %q: repaired %d shard and manifest files", i, sub, n)
+			}
+			repaired += n
+		}
+		files += store.Len()
+	}
+	log.Printf("Scrubbed %d files: %d shard and manifest files repaired, %d files lost", files, repaired, lost)
+	if lost > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadSigningKey loads the tree head signing key, falling back to a temporary key
+func loadSigningKey(cfg *config.Config) ed25519.PrivateKey {
+	var key ed25519.PrivateKey
+	if cfg.SigningKeyFile == "" {
+		_, generated, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			log.Fatalf("Failed to generate signing key: %v", err)
+		}
+		log.Println("No SIGNING_KEY_FILE configured, signing tree heads with a temporary key")
+		key = generated
+	} else {
+		loaded, err := treehead.LoadOrCreateKey(cfg.SigningKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+		key = loaded
+	}
+
+	log.Printf("Tree head public key: %s", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+	return key
+}
+
+// startReplication makes the server a read-only follower of the configured leader
+func startReplication(ctx context.Context, srv *server.Server, cfg *config.Config) {
+	if cfg.TreeMode == server.TreeModeMMR {
+		log.Fatalf("Invalid configuration: LEADER_URL can't be used with TREE_MODE=mmr, replication needs consistency proofs")
+	}
+	leaderKey, err := treehead.ParsePublicKey(cfg.LeaderKey)
+	if err != nil {
+		log.Fatalf("Invalid configuration: LEADER_PUBLIC_KEY: %v", err)
+	}
+
+	srv.ReadOnly = true
+	follower := replication.NewFollower(cfg.LeaderURL, leaderKey, srv)
+	follower.SetAuthToken(cfg.APIToken)
+	go func() {
+		log.Printf("Replicating from %s every %s", cfg.LeaderURL, cfg.ReplicationInterval)
+		err := follower.Run(ctx, cfg.ReplicationInterval)
+		if errors.Is(err, replication.ErrFork) {
+			log.Printf("Replication stopped: %v", err)
+		}
+	}()
+}
+
+// epochInterval enables upload batching when EPOCH_INTERVAL is set and
+// returns how often epochs are sealed; timestamps are batched either way
+func epochInterval(srv *server.Server, cfg *config.Config) time.Duration {
+	if cfg.EpochInterval <= 0 {
+		return cfg.TimestampInterval
+	}
+	if cfg.EpochInterval > cfg.MaxMergeDelay {
+		log.Fatalf("Invalid configuration: EPOCH_INTERVAL %s is longer than MAX_MERGE_DELAY %s", cfg.EpochInterval, cfg.MaxMergeDelay)
+	}
+
+	srv.BatchUploads = true
+	srv.MaxBatchSize = cfg.EpochMaxLeaves
+	log.Printf("Batching uploads into epochs of %s or %d files", cfg.EpochInterval, cfg.EpochMaxLeaves)
+	return cfg.EpochInterval
+}
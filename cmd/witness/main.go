@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/witness"
+	"github.com/akhilesharora/go-merkle/pkg/config"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	logKey, err := treehead.ParsePublicKey(cfg.LogPublicKey)
+	if err != nil {
+		log.Fatalf("Invalid configuration: LOG_PUBLIC_KEY: %v", err)
+	}
+	key, err := treehead.LoadOrCreateKey(cfg.WitnessKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load witness key: %v", err)
+	}
+	log.Printf("Witness public key: %s", hex.EncodeToString(key.Public().(ed25519.PublicKey)))
+
+	w := witness.New(key, logKey, cfg.WitnessStateFile)
+	router := mux.NewRouter()
+	router.HandleFunc("/cosign", w.CosignHandler).Methods("POST")
+
+	httpServer := &http.Server{
+		Addr:    cfg.ServerAddress(),
+		Handler: router,
+	}
+
+	go func() {
+		log.Printf("Witness is running on %s", cfg.ServerAddress())
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("HTTP server ListenAndServe: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down witness...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Fatalf("Witness forced to shutdown: %v", err)
+	}
+}
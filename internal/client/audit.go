@@ -0,0 +1,144 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// ChunkRootsFile is where UploadFiles records the chunk roots of uploaded files for later audits
+const ChunkRootsFile = "chunk_roots.json"
+
+// ErrAuditFailed is returned when the server cannot prove it holds the audited chunks
+var ErrAuditFailed = errors.New("storage audit failed")
+
+// ChunkRecord is the commitment to a file's chunks that the client keeps
+// after uploading it, so the file can be audited without a local copy
+type ChunkRecord struct {
+	FileIndex int `json:"fileIndex"`
+	// Root is the hex-encoded root of merkle.BuildChunkTree of the file
+	Root   string `json:"root"`
+	Chunks int    `json:"chunks"`
+}
+
+// NewChunkRecord computes the chunk record for a file
+func NewChunkRecord(fileIndex int, data []byte) ChunkRecord {
+	root := merkle.BuildChunkTree(data).Root.Hash
+	return ChunkRecord{
+		FileIndex: fileIndex,
+		Root:      hex.EncodeToString(root[:]),
+		Chunks:    len(merkle.SplitChunks(data)),
+	}
+}
+
+// LoadChunkRecords reads the chunk records saved in path
+func LoadChunkRecords(path string) ([]ChunkRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ChunkRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("decoding chunk records: %w", err)
+	}
+	return records, nil
+}
+
+// saveChunkRecords appends records to the ones already saved in path
+func saveChunkRecords(path string, records []ChunkRecord) error {
+	existing, err := LoadChunkRecords(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	data, err := json.MarshalIndent(append(existing, records...), "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(path, data)
+}
+
+type auditRequest struct {
+	Nonce  string `json:"nonce"`
+	Chunks []int  `json:"chunks"`
+}
+
+type auditResponse struct {
+	Nonce  string `json:"nonce"`
+	Chunks []struct {
+		Index      int      `json:"index"`
+		Data       []byte   `json:"data"`
+		Proof      []string `json:"proof"`
+		Directions []bool   `json:"directions"`
+	} `json:"chunks"`
+}
+
+// AuditFile challenges the server to prove it still holds samples randomly
+// chosen chunks of the file and returns how many chunks were checked. Errors
+// wrapping ErrAuditFailed mean the server failed the challenge.
+func (c *Client) AuditFile(record ChunkRecord, samples int) (int, error) {
+	root, err := hex.DecodeString(record.Root)
+	if err != nil || len(root) != 32 {
+		return 0, fmt.Errorf("invalid chunk root %q", record.Root)
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	samples = min(samples, record.Chunks)
+	request := auditRequest{Nonce: hex.EncodeToString(nonce), Chunks: mathrand.Perm(record.Chunks)[:samples]}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.post(fmt.Sprintf("%s/audit/%d", c.serverURL, record.FileIndex), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%w: file %d: %s", ErrAuditFailed, record.FileIndex, resp.Status)
+	}
+
+	var response auditResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("%w: file %d: %v", ErrAuditFailed, record.FileIndex, err)
+	}
+	if response.Nonce != request.Nonce || len(response.Chunks) != len(request.Chunks) {
+		return 0, fmt.Errorf("%w: file %d: response does not match the challenge", ErrAuditFailed, record.FileIndex)
+	}
+	for i, chunk := range response.Chunks {
+		proof := make([][32]byte, len(chunk.Proof))
+		for j, encoded := range chunk.Proof {
+			hash, err := hex.DecodeString(encoded)
+			if err != nil || len(hash) != 32 {
+				return 0, fmt.Errorf("%w: file %d: invalid proof hash %q", ErrAuditFailed, record.FileIndex, encoded)
+			}
+			proof[j] = [32]byte(hash)
+		}
+		if chunk.Index != request.Chunks[i] ||
+			!merkle.VerifyProofAt(merkle.CreateHash(chunk.Data), chunk.Index, record.Chunks, proof, chunk.Directions, [32]byte(root)) {
+			return 0, fmt.Errorf("%w: file %d: chunk %d does not match the recorded chunk root", ErrAuditFailed, record.FileIndex, request.Chunks[i])
+		}
+	}
+	return samples, nil
+}
+
+// DetectionProbability is the probability that checking samples uniformly
+// random chunks catches a server that has lost the given fraction of them
+func DetectionProbability(samples int, loss float64) float64 {
+	return 1 - math.Pow(1-loss, float64(samples))
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestAuditFile(t *testing.T) {
+	srv := server.NewServer()
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	data := bytes.Repeat([]byte("x"), 3*merkle.ChunkSize+100)
+	response, err := client.uploadFile("big.bin", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "chunk_roots.json")
+	if err := saveChunkRecords(path, []ChunkRecord{NewChunkRecord(response.FileIndex, data)}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadChunkRecords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := records[0]
+	if record.Chunks != 4 {
+		t.Fatalf("expected 4 chunks, got %d", record.Chunks)
+	}
+
+	checked, err := client.AuditFile(record, 16)
+	if err != nil {
+		t.Fatalf("expected audit to pass, got %v", err)
+	}
+	if checked != record.Chunks {
+		t.Fatalf("expected all %d chunks checked, got %d", record.Chunks, checked)
+	}
+
+	// Corrupt a single byte; auditing every chunk must notice
+	srv.Files[0][merkle.ChunkSize+1] ^= 0xff
+	if _, err := client.AuditFile(record, record.Chunks); !errors.Is(err, ErrAuditFailed) {
+		t.Fatalf("expected ErrAuditFailed for a corrupted file, got %v", err)
+	}
+}
+
+func TestDetectionProbability(t *testing.T) {
+	if p := DetectionProbability(0, 0.5); p != 0 {
+		t.Errorf("expected 0 with no samples, got %f", p)
+	}
+	if p := DetectionProbability(2, 0.5); p != 0.75 {
+		t.Errorf("expected 0.75, got %f", p)
+	}
+}
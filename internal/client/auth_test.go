@@ -0,0 +1,34 @@
+package client
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+func TestAuthToken(t *testing.T) {
+	auth, err := api.NewAuthenticator([]string{"reader-key:read"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer()
+	srv.UploadFile("a.txt", []byte("hello"))
+	ts := httptest.NewServer(api.NewRouter(srv, api.RouterOptions{Auth: auth}))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	if _, err := client.DownloadFile(0); err == nil {
+		t.Error("DownloadFile: Expected error without credentials")
+	}
+	client.AuthToken = "reader-key"
+	data, err := client.DownloadFile(0)
+	if err != nil || !bytes.Equal(data, []byte("hello")) {
+		t.Errorf("DownloadFile: Expected the file with credentials, got %q (%v)", data, err)
+	}
+	if _, _, err := client.GetMerkleProofAt(1, 0); err != nil {
+		t.Errorf("GetMerkleProofAt: Unexpected error with credentials: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,8 +12,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 )
 
 type ClientInterface interface {
@@ -23,6 +26,19 @@ type ClientInterface interface {
 
 type Client struct {
 	serverURL string
+	// EpochWait makes UploadFiles wait up to this long for the server to seal
+	// the uploads into a tree head; zero returns as soon as they are accepted
+	EpochWait time.Duration
+	// EncryptionKey makes UploadFiles encrypt files with Encrypt before
+	// uploading them, so the server and its tree only see ciphertext
+	EncryptionKey []byte
+	// CommitPlaintext adds a plaintext commitment to encrypted uploads
+	CommitPlaintext bool
+	// treeMode caches the server's tree mode once TreeMode has fetched it
+	treeMode string
+	// AuthToken is sent as a bearer token with every request to the server:
+	// an API key or a signed token; empty sends no credentials
+	AuthToken string
 }
 
 func NewClient(serverURL string) *Client {
@@ -31,20 +47,57 @@ func NewClient(serverURL string) *Client {
 
 func (c *Client) UploadFiles(files []string) (string, error) {
 	var merkleFiles []merkle.File
+	var promises []*treehead.InclusionPromise
+	var chunkRecords []ChunkRecord
+	lastEpoch := 0
 	for _, file := range files {
 		data, err := os.ReadFile(file)
 		if err != nil {
 			return "", err
 		}
+		if c.EncryptionKey != nil {
+			data, err = Encrypt(c.EncryptionKey, data, c.CommitPlaintext)
+			if err != nil {
+				return "", fmt.Errorf("encrypting %s: %w", file, err)
+			}
+		}
 		merkleFiles = append(merkleFiles, merkle.File{Data: string(data)})
 
-		if err := c.uploadFile(file, data); err != nil {
+		response, err := c.uploadFile(file, data)
+		if err != nil {
+			return "", err
+		}
+		if response.Promise != nil {
+			promises = append(promises, response.Promise)
+		}
+		if response.FileIndex >= 0 {
+			chunkRecords = append(chunkRecords, NewChunkRecord(response.FileIndex, data))
+		}
+		lastEpoch = max(lastEpoch, response.Epoch)
+	}
+	if len(promises) > 0 {
+		if err := savePromises(PromisesFile, promises); err != nil {
+			return "", err
+		}
+	}
+	if len(chunkRecords) > 0 {
+		if err := saveChunkRecords(ChunkRootsFile, chunkRecords); err != nil {
+			return "", err
+		}
+	}
+
+	if c.EpochWait > 0 && lastEpoch > 0 {
+		epoch, err := c.WaitForEpoch(lastEpoch, c.EpochWait)
+		if err != nil {
 			return "", err
 		}
+		log.Printf("Uploads sealed in epoch %d, tree size %d", epoch.Number, epoch.TreeSize)
 	}
 
-	merkleTree := merkle.BuildMerkleTree(merkleFiles)
-	rootHash := merkleTree.Root.Hash
+	rootHash, err := c.localRoot(merkleFiles)
+	if err != nil {
+		return "", err
+	}
 
 	if err := saveRootHash(rootHash); err != nil {
 		return "", err
@@ -53,37 +106,136 @@ func (c *Client) UploadFiles(files []string) (string, error) {
 	return hex.EncodeToString(rootHash[:]), nil
 }
 
-func (c *Client) uploadFile(file string, data []byte) error {
+// TreeModeMMR is the TREE_MODE of servers keeping a Merkle Mountain Range.
+// Such servers have no node hashes or consistency proofs, so diffs, monitors
+// and replicas don't work against them.
+const TreeModeMMR = "mmr"
+
+// ErrUnsupportedTreeMode is returned by features that need node hashes or
+// consistency proofs when the server keeps a Merkle Mountain Range
+var ErrUnsupportedTreeMode = errors.New("not available when the server runs with TREE_MODE=mmr")
+
+// infoResponse is the body of an /info response
+type infoResponse struct {
+	TreeMode string `json:"treeMode"`
+}
+
+// TreeMode fetches the server's TREE_MODE, binary or mmr, once and caches it
+func (c *Client) TreeMode() (string, error) {
+	if c.treeMode == "" {
+		var response infoResponse
+		if err := c.getJSON("/info", &response); err != nil {
+			return "", fmt.Errorf("fetching tree mode: %w", err)
+		}
+		c.treeMode = response.TreeMode
+	}
+	return c.treeMode, nil
+}
+
+// checkTreeMode returns an error wrapping ErrUnsupportedTreeMode for a server
+// keeping a Merkle Mountain Range
+func (c *Client) checkTreeMode(feature string) error {
+	mode, err := c.TreeMode()
+	if err != nil {
+		return err
+	}
+	if mode == TreeModeMMR {
+		return fmt.Errorf("%s: %w", feature, ErrUnsupportedTreeMode)
+	}
+	return nil
+}
+
+// localRoot computes the root the server's tree would have if it held exactly files
+func (c *Client) localRoot(files []merkle.File) ([32]byte, error) {
+	mode, err := c.TreeMode()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if mode == TreeModeMMR {
+		return merkle.NewMountainRange(files).Root(), nil
+	}
+	return merkle.BuildMerkleTree(files).Root.Hash, nil
+}
+
+type uploadResponse struct {
+	Message   string                     `json:"message"`
+	FileIndex int                        `json:"fileIndex"`
+	Epoch     int                        `json:"epoch"`
+	Promise   *treehead.InclusionPromise `json:"promise"`
+}
+
+// uploadFile uploads one file and returns the server's response, including
+// its inclusion promise if the server signs one
+func (c *Client) uploadFile(file string, data []byte) (*uploadResponse, error) {
 	body := new(bytes.Buffer)
 	writer := multipart.NewWriter(body)
 	part, err := writer.CreateFormFile("file", filepath.Base(file))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	_, err = part.Write(data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	writer.Close()
 
 	req, err := http.NewRequest("POST", c.serverURL+"/upload?filename="+filepath.Base(file), body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to upload file: %s", resp.Status)
+		return nil, fmt.Errorf("failed to upload file: %s", resp.Status)
+	}
+
+	// FileIndex stays -1 for servers that don't report it
+	response := uploadResponse{FileIndex: -1}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decoding upload response: %w", err)
+	}
+	if response.Promise != nil {
+		leaf, err := response.Promise.Leaf()
+		if err != nil {
+			return nil, err
+		}
+		if leaf != merkle.CreateHash(data) || response.Promise.Index != response.FileIndex {
+			return nil, fmt.Errorf("inclusion promise for %s does not match the uploaded file", file)
+		}
 	}
 
 	log.Printf("Uploaded file: %s", file)
-	return nil
+	return &response, nil
+}
+
+// do sends a request to the server with the client's credentials
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (c *Client) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *Client) post(url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
 }
 
 func saveRootHash(rootHash [32]byte) error {
@@ -95,12 +247,16 @@ func saveRootHash(rootHash [32]byte) error {
 	return nil
 }
 
-func (c *Client) downloadFile(fileIndex int) ([]byte, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/download/%d", c.serverURL, fileIndex))
+// DownloadFile fetches the content of the file at fileIndex without verifying it
+func (c *Client) DownloadFile(fileIndex int) ([]byte, error) {
+	resp, err := c.get(fmt.Sprintf("%s/download/%d", c.serverURL, fileIndex))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: %s", resp.Status)
+	}
 
 	fileData, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -112,7 +268,7 @@ func (c *Client) downloadFile(fileIndex int) ([]byte, error) {
 }
 
 func (c *Client) getMerkleProof(fileIndex int) (*proofResponse, error) {
-	resp, err := http.Get(fmt.Sprintf("%s/proof/%d", c.serverURL, fileIndex))
+	resp, err := c.get(fmt.Sprintf("%s/proof/%d", c.serverURL, fileIndex))
 	if err != nil {
 		return nil, err
 	}
@@ -132,11 +288,17 @@ type proofResponse struct {
 	Directions []bool   `json:"directions"`
 }
 
+// VerifyProof checks a proof as returned by the server, which runs from the
+// root down, by hashing from the leaf up
 func (c *Client) VerifyProof(fileHash [32]byte, proof [][]byte, directions []bool, rootHash []byte) bool {
+	if len(proof) != len(directions) {
+		return false
+	}
 	currentHash := fileHash
 	log.Printf("Initial fileHash: %x", currentHash)
 
-	for i, proofElement := range proof {
+	for i := len(proof) - 1; i >= 0; i-- {
+		proofElement := proof[i]
 		log.Printf("Proof element %d: %x", i, proofElement)
 		log.Printf("Direction %d: %v", i, directions[i])
 
@@ -155,7 +317,7 @@ func (c *Client) VerifyProof(fileHash [32]byte, proof [][]byte, directions []boo
 }
 
 func (c *Client) DownloadAndVerifyFile(fileIndex int) ([]byte, error) {
-	fileData, err := c.downloadFile(fileIndex)
+	fileData, err := c.DownloadFile(fileIndex)
 	if err != nil {
 		return nil, fmt.Errorf("download error: %w", err)
 	}
@@ -181,3 +343,17 @@ func (c *Client) DownloadAndVerifyFile(fileIndex int) ([]byte, error) {
 	log.Printf("Verified file index: %d", fileIndex)
 	return fileData, nil
 }
+
+// DownloadAndDecryptFile downloads a file uploaded with an EncryptionKey,
+// verifies the ciphertext against the stored root hash and decrypts it,
+// checking the plaintext commitment if the file has one
+func (c *Client) DownloadAndDecryptFile(fileIndex int) ([]byte, error) {
+	if c.EncryptionKey == nil {
+		return nil, fmt.Errorf("no encryption key configured")
+	}
+	ciphertext, err := c.DownloadAndVerifyFile(fileIndex)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(c.EncryptionKey, ciphertext)
+}
@@ -12,8 +12,12 @@ import (
 	"github.com/akhilesharora/go-merkle/pkg/merkle"
 )
 
-// Mock server handler for upload
+// Mock server handler for upload; the client also asks for the tree mode
 func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/info" {
+		w.Write([]byte(`{"treeMode":"binary"}`))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptionKeySize is the size of the AES-256 keys files are encrypted with
+const EncryptionKeySize = 32
+
+// envelopeMagic starts every encrypted file, followed by a flags byte
+var envelopeMagic = []byte("GMENC1")
+
+const (
+	// flagCommitted marks envelopes carrying a plaintext commitment
+	flagCommitted = 1 << iota
+)
+
+const commitmentSaltSize = 32
+
+var (
+	// ErrNotEncrypted is returned by Decrypt for data that is not an encryption envelope
+	ErrNotEncrypted = errors.New("file is not encrypted")
+	// ErrCommitmentMismatch means the decrypted file does not open the envelope's plaintext commitment
+	ErrCommitmentMismatch = errors.New("decrypted file does not match its plaintext commitment")
+)
+
+// LoadOrCreateEncryptionKey reads a hex-encoded AES-256 key from path,
+// generating and saving a new one if the file does not exist. The key never
+// leaves the client.
+func LoadOrCreateEncryptionKey(path string) ([]byte, error) {
+	key, err := LoadEncryptionKey(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("saving encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadEncryptionKey reads a hex-encoded AES-256 key from path
+func LoadEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading encryption key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key %s is not a hex-encoded 32-byte key", path)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-GCM under key. The envelope is
+//
+//	magic | flags | [commitment] | nonce | ciphertext
+//
+// With commit set, the header carries SHA-256(salt || plaintext) and the
+// random salt is encrypted along with the plaintext. The commitment lets
+// the owner prove which plaintext was uploaded by revealing the salt, and
+// unlike GCM alone it binds the ciphertext to a single plaintext under any
+// key. The header is authenticated as additional data.
+func Encrypt(key, plaintext []byte, commit bool) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, envelopeMagic...)
+	sealed := plaintext
+	if commit {
+		salt := make([]byte, commitmentSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		commitment := plaintextCommitment(salt, plaintext)
+		header = append(header, flagCommitted)
+		header = append(header, commitment[:]...)
+		sealed = append(salt, plaintext...)
+	} else {
+		header = append(header, 0)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	envelope := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(envelope, nonce, sealed, header), nil
+}
+
+// Decrypt opens an envelope made by Encrypt and checks its plaintext commitment, if any
+func Decrypt(key, envelope []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header, committed, err := envelopeHeader(envelope)
+	if err != nil {
+		return nil, err
+	}
+	rest := envelope[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	opened, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting file: %w", err)
+	}
+	if !committed {
+		return opened, nil
+	}
+
+	if len(opened) < commitmentSaltSize {
+		return nil, ErrCommitmentMismatch
+	}
+	salt, plaintext := opened[:commitmentSaltSize], opened[commitmentSaltSize:]
+	commitment := plaintextCommitment(salt, plaintext)
+	if !bytes.Equal(commitment[:], header[len(envelopeMagic)+1:]) {
+		return nil, ErrCommitmentMismatch
+	}
+	return plaintext, nil
+}
+
+// envelopeHeader returns the authenticated header of an envelope and whether it carries a commitment
+func envelopeHeader(envelope []byte) ([]byte, bool, error) {
+	if len(envelope) <= len(envelopeMagic) || !bytes.HasPrefix(envelope, envelopeMagic) {
+		return nil, false, ErrNotEncrypted
+	}
+	flags := envelope[len(envelopeMagic)]
+	size := len(envelopeMagic) + 1
+	if flags&flagCommitted != 0 {
+		size += sha256.Size
+	}
+	if len(envelope) < size {
+		return nil, false, fmt.Errorf("encrypted file is truncated")
+	}
+	return envelope[:size], flags&flagCommitted != 0, nil
+}
+
+func plaintextCommitment(salt, plaintext []byte) [32]byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(plaintext)
+	return [32]byte(h.Sum(nil))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := LoadOrCreateEncryptionKey(filepath.Join(t.TempDir(), "file.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("quarterly numbers")
+
+	for _, commit := range []bool{false, true} {
+		envelope, err := Encrypt(key, plaintext, commit)
+		if err != nil {
+			t.Fatalf("commit=%v: expected no error, got %v", commit, err)
+		}
+		if bytes.Contains(envelope, plaintext) {
+			t.Fatalf("commit=%v: envelope contains the plaintext", commit)
+		}
+		decrypted, err := Decrypt(key, envelope)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("commit=%v: expected plaintext back, got %q (%v)", commit, decrypted, err)
+		}
+
+		otherKey := bytes.Repeat([]byte{1}, EncryptionKeySize)
+		if _, err := Decrypt(otherKey, envelope); err == nil {
+			t.Errorf("commit=%v: expected error for the wrong key", commit)
+		}
+		tampered := append([]byte{}, envelope...)
+		tampered[len(envelopeMagic)+1] ^= 1
+		if _, err := Decrypt(key, tampered); err == nil {
+			t.Errorf("commit=%v: expected error for a tampered envelope", commit)
+		}
+	}
+
+	if _, err := Decrypt(key, plaintext); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestUploadEncrypted(t *testing.T) {
+	srv := server.NewServer()
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+	defer os.Remove("root_hash.txt")
+	defer os.Remove(ChunkRootsFile)
+
+	path := filepath.Join(t.TempDir(), "secret.txt")
+	plaintext := []byte("attack at dawn")
+	if err := os.WriteFile(path, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(ts.URL)
+	client.EncryptionKey = bytes.Repeat([]byte{7}, EncryptionKeySize)
+	client.CommitPlaintext = true
+	if _, err := client.UploadFiles([]string{path}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	stored, _ := srv.GetFileData(0)
+	if bytes.Contains(stored, plaintext) {
+		t.Fatal("server stored the plaintext")
+	}
+	decrypted, err := client.DownloadAndDecryptFile(0)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected plaintext back, got %q (%v)", decrypted, err)
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// Epoch is the latest sealed epoch reported by GET /epoch
+type Epoch struct {
+	Number   int                      `json:"epoch"`
+	TreeSize int                      `json:"treeSize"`
+	TreeHead *treehead.SignedTreeHead `json:"treeHead"`
+}
+
+// maxEpochPoll is the longest single long-poll request WaitForEpoch makes
+const maxEpochPoll = 30 * time.Second
+
+// WaitForEpoch long-polls the server until the given epoch has been sealed,
+// giving up after timeout
+func (c *Client) WaitForEpoch(epoch int, timeout time.Duration) (*Epoch, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		poll := min(time.Until(deadline), maxEpochPoll)
+		if poll < 0 {
+			poll = 0
+		}
+
+		var current Epoch
+		path := fmt.Sprintf("/epoch?wait=%d&timeout=%s", epoch, poll)
+		if err := c.getJSON(path, &current); err != nil {
+			return nil, err
+		}
+		if current.Number >= epoch {
+			return &current, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("epoch %d not sealed after %s, latest is %d", epoch, timeout, current.Number)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/api"
+)
+
+func TestWaitForEpoch(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	srv := signedServer(private)
+	srv.BatchUploads = true
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	response, err := client.uploadFile("a.txt", []byte("a"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if response.Epoch != 1 {
+		t.Fatalf("expected pending epoch 1, got %d", response.Epoch)
+	}
+
+	if _, err := client.WaitForEpoch(response.Epoch, 10*time.Millisecond); err == nil {
+		t.Fatal("expected error while the epoch is not sealed")
+	}
+
+	time.AfterFunc(20*time.Millisecond, srv.Seal)
+	epoch, err := client.WaitForEpoch(response.Epoch, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if epoch.Number != 1 || epoch.TreeSize != 1 || epoch.TreeHead == nil {
+		t.Fatalf("unexpected epoch %+v", epoch)
+	}
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// ErrMisbehavior is returned by Monitor.Check when the server presents a tree
+// head that contradicts one it signed earlier: a smaller tree, a different
+// root for the same size, or a tree that does not extend the trusted one.
+var ErrMisbehavior = errors.New("server misbehavior detected")
+
+// Monitor watches a server's signed tree heads and checks that every head it
+// observes is an append-only extension of the last one it trusted.
+type Monitor struct {
+	client    *Client
+	key       ed25519.PublicKey
+	statePath string
+	// WebhookURL receives a JSON alert on misbehavior when set
+	WebhookURL string
+	// Witnesses and Quorum require tree heads to be cosigned by at least Quorum of the given witnesses
+	Witnesses []ed25519.PublicKey
+	Quorum    int
+}
+
+// Alert describes a misbehavior reported to the webhook
+type Alert struct {
+	Server   string                   `json:"server"`
+	Message  string                   `json:"message"`
+	Trusted  *treehead.SignedTreeHead `json:"trusted,omitempty"`
+	Observed *treehead.SignedTreeHead `json:"observed"`
+}
+
+// NewMonitor creates a monitor for the client's server whose tree heads must
+// be signed by key. The latest trusted head is persisted in statePath.
+func NewMonitor(c *Client, key ed25519.PublicKey, statePath string) *Monitor {
+	return &Monitor{client: c, key: key, statePath: statePath}
+}
+
+// Trusted returns the last tree head the monitor accepted, or nil if it has not accepted one yet
+func (m *Monitor) Trusted() (*treehead.SignedTreeHead, error) {
+	data, err := os.ReadFile(m.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading monitor state: %w", err)
+	}
+
+	var sth treehead.SignedTreeHead
+	if err := json.Unmarshal(data, &sth); err != nil {
+		return nil, fmt.Errorf("decoding monitor state: %w", err)
+	}
+	if err := sth.Verify(m.key); err != nil {
+		return nil, fmt.Errorf("monitor state: %w", err)
+	}
+	return &sth, nil
+}
+
+// Check fetches the current tree head, verifies it against the trusted head
+// and persists it as the new trusted head. Errors wrapping ErrMisbehavior
+// have already been reported to the webhook.
+func (m *Monitor) Check() (*treehead.SignedTreeHead, error) {
+	if err := m.client.checkTreeMode("monitor"); err != nil {
+		return nil, err
+	}
+	trusted, err := m.Trusted()
+	if err != nil {
+		return nil, err
+	}
+
+	observed, err := m.client.GetSignedTreeHead()
+	if err != nil {
+		return nil, fmt.Errorf("fetching tree head: %w", err)
+	}
+	if err := observed.Verify(m.key); err != nil {
+		// A bad signature may be a proxy or a misconfigured key, not a server lying
+		return nil, fmt.Errorf("tree head: %w", err)
+	}
+	if m.Quorum > 0 {
+		if err := observed.VerifyQuorum(m.Witnesses, m.Quorum); err != nil {
+			return nil, fmt.Errorf("tree head size %d: %w", observed.TreeSize, err)
+		}
+	}
+
+	if err := m.checkExtends(trusted, observed); err != nil {
+		m.alert(err, trusted, observed)
+		return nil, fmt.Errorf("%w: %v", ErrMisbehavior, err)
+	}
+
+	data, err := json.Marshal(observed)
+	if err != nil {
+		return nil, err
+	}
+	if err := storage.WriteFileAtomic(m.statePath, data); err != nil {
+		return nil, fmt.Errorf("saving monitor state: %w", err)
+	}
+	return observed, nil
+}
+
+// Run checks every interval until the server misbehaves, returning the
+// misbehavior. Other errors are logged and retried.
+func (m *Monitor) Run(interval time.Duration) error {
+	for {
+		sth, err := m.Check()
+		if errors.Is(err, ErrMisbehavior) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Monitor check failed: %v", err)
+		} else {
+			log.Printf("Trusted tree head: size %d, root %s", sth.TreeSize, sth.RootHash)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// checkExtends verifies that observed is an append-only extension of trusted
+func (m *Monitor) checkExtends(trusted, observed *treehead.SignedTreeHead) error {
+	if trusted == nil {
+		return nil
+	}
+	if observed.Timestamp < trusted.Timestamp {
+		return fmt.Errorf("tree head timestamp %s is older than trusted %s", observed.Time(), trusted.Time())
+	}
+	if observed.TreeSize < trusted.TreeSize {
+		return fmt.Errorf("tree rewound from size %d to %d", trusted.TreeSize, observed.TreeSize)
+	}
+
+	trustedRoot, err := trusted.Root()
+	if err != nil {
+		return err
+	}
+	observedRoot, err := observed.Root()
+	if err != nil {
+		return err
+	}
+	if observed.TreeSize == trusted.TreeSize {
+		if observedRoot != trustedRoot {
+			return fmt.Errorf("split view: two different roots signed for tree size %d", trusted.TreeSize)
+		}
+		return nil
+	}
+	if trusted.TreeSize == 0 {
+		return nil
+	}
+
+	proof, err := m.client.GetConsistencyProof(trusted.TreeSize, observed.TreeSize)
+	if err != nil {
+		return fmt.Errorf("no consistency proof from size %d to %d: %v", trusted.TreeSize, observed.TreeSize, err)
+	}
+	if !merkle.VerifyConsistency(trusted.TreeSize, observed.TreeSize, trustedRoot, observedRoot, proof) {
+		return fmt.Errorf("split view: tree of size %d does not extend trusted tree of size %d", observed.TreeSize, trusted.TreeSize)
+	}
+	return nil
+}
+
+// alert logs the misbehavior and posts it to the webhook
+func (m *Monitor) alert(err error, trusted, observed *treehead.SignedTreeHead) {
+	log.Printf("MONITOR ALERT: %s: %v", m.client.serverURL, err)
+	if m.WebhookURL == "" {
+		return
+	}
+
+	body, _ := json.Marshal(Alert{
+		Server:   m.client.serverURL,
+		Message:  err.Error(),
+		Trusted:  trusted,
+		Observed: observed,
+	})
+	resp, postErr := http.Post(m.WebhookURL, "application/json", bytes.NewReader(body))
+	if postErr != nil {
+		log.Printf("Failed to send alert to webhook: %v", postErr)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Webhook rejected alert: %s", resp.Status)
+	}
+}
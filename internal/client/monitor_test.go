@@ -0,0 +1,146 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// switchableServer serves a test HTTP server whose backing go-merkle server can be swapped
+type switchableServer struct {
+	handler http.Handler
+}
+
+func (s *switchableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+func signedServer(key ed25519.PrivateKey, files ...string) *server.Server {
+	srv := server.NewServer()
+	srv.SigningKey = key
+	for _, file := range files {
+		srv.UploadFile(file, []byte(file))
+	}
+	return srv
+}
+
+func TestMonitorAcceptsGrowth(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	srv := signedServer(private, "a", "b")
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	monitor := NewMonitor(NewClient(ts.URL), public, filepath.Join(t.TempDir(), "trusted.json"))
+	if _, err := monitor.Check(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	srv.UploadFile("c", []byte("c"))
+	srv.UploadFile("d", []byte("d"))
+	srv.UploadFile("e", []byte("e"))
+	sth, err := monitor.Check()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sth.TreeSize != 5 {
+		t.Fatalf("expected tree size 5, got %d", sth.TreeSize)
+	}
+
+	trusted, err := monitor.Trusted()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if trusted.TreeSize != 5 || trusted.RootHash != sth.RootHash {
+		t.Fatalf("expected persisted head to match the last check, got %+v", trusted)
+	}
+}
+
+func TestMonitorDetectsMisbehavior(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name  string
+		after *server.Server
+	}{
+		{"rewind", signedServer(private, "a", "b")},
+		{"split view", signedServer(private, "a", "x", "c")},
+		{"fork", signedServer(private, "a", "x", "c", "d", "e")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &switchableServer{handler: api.SetupRoutes(signedServer(private, "a", "b", "c"))}
+			ts := httptest.NewServer(backend)
+			defer ts.Close()
+
+			var alert Alert
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&alert)
+			}))
+			defer webhook.Close()
+
+			monitor := NewMonitor(NewClient(ts.URL), public, filepath.Join(t.TempDir(), "trusted.json"))
+			monitor.WebhookURL = webhook.URL
+			if _, err := monitor.Check(); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			backend.handler = api.SetupRoutes(tt.after)
+			if _, err := monitor.Check(); !errors.Is(err, ErrMisbehavior) {
+				t.Fatalf("expected ErrMisbehavior, got %v", err)
+			}
+			if alert.Trusted == nil || alert.Trusted.TreeSize != 3 || alert.Observed == nil {
+				t.Fatalf("expected webhook alert with both tree heads, got %+v", alert)
+			}
+
+			trusted, _ := monitor.Trusted()
+			if trusted.TreeSize != 3 {
+				t.Fatalf("expected trusted head to stay at size 3, got %d", trusted.TreeSize)
+			}
+		})
+	}
+}
+
+func TestMonitorRejectsUnknownKey(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(nil)
+	other, _, _ := ed25519.GenerateKey(nil)
+	ts := httptest.NewServer(api.SetupRoutes(signedServer(private, "a")))
+	defer ts.Close()
+
+	monitor := NewMonitor(NewClient(ts.URL), other, filepath.Join(t.TempDir(), "trusted.json"))
+	_, err := monitor.Check()
+	if err == nil || errors.Is(err, ErrMisbehavior) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestMonitorRequiresQuorum(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	witnessPublic, witnessKey, _ := ed25519.GenerateKey(nil)
+	srv := signedServer(private, "a")
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	monitor := NewMonitor(NewClient(ts.URL), public, filepath.Join(t.TempDir(), "trusted.json"))
+	monitor.Witnesses = []ed25519.PublicKey{witnessPublic}
+	monitor.Quorum = 1
+	if _, err := monitor.Check(); err == nil {
+		t.Fatal("expected error for a tree head without cosignatures")
+	}
+
+	sth, _ := srv.GetSignedTreeHead()
+	cosig, _ := treehead.Cosign(witnessKey, sth, time.Now())
+	srv.AddCosignatures(sth, []treehead.Cosignature{cosig})
+	if _, err := monitor.Check(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// PromisesFile is where UploadFiles records the inclusion promises it receives
+const PromisesFile = "promises.json"
+
+var (
+	// ErrPromisePending means the file is not in a signed tree head yet but the promise has not expired
+	ErrPromisePending = errors.New("file is not yet included in a signed tree head")
+	// ErrPromiseBroken means the server did not include the file within its maximum merge delay
+	ErrPromiseBroken = errors.New("server broke its inclusion promise")
+)
+
+// LoadPromises reads the inclusion promises recorded in path
+func LoadPromises(path string) ([]*treehead.InclusionPromise, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var promises []*treehead.InclusionPromise
+	if err := json.Unmarshal(data, &promises); err != nil {
+		return nil, fmt.Errorf("decoding promises: %w", err)
+	}
+	return promises, nil
+}
+
+// savePromises appends promises to the ones already recorded in path
+func savePromises(path string, promises []*treehead.InclusionPromise) error {
+	existing, err := LoadPromises(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	data, err := json.MarshalIndent(append(existing, promises...), "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(path, data)
+}
+
+// CheckInclusionPromise checks that the promised leaf is included in the
+// server's current signed tree head. It returns ErrPromisePending if the
+// leaf is not included yet but the deadline has not passed, and an error
+// wrapping ErrPromiseBroken if the deadline has passed, the tree head that
+// includes the leaf was signed after the deadline or a different leaf was
+// included at the promised index.
+func (c *Client) CheckInclusionPromise(promise *treehead.InclusionPromise, key ed25519.PublicKey) error {
+	if err := promise.Verify(key); err != nil {
+		return err
+	}
+	leaf, err := promise.Leaf()
+	if err != nil {
+		return err
+	}
+
+	sth, err := c.GetSignedTreeHead()
+	if err != nil {
+		return fmt.Errorf("fetching tree head: %w", err)
+	}
+	if err := sth.Verify(key); err != nil {
+		return fmt.Errorf("tree head: %w", err)
+	}
+
+	if sth.TreeSize <= promise.Index {
+		if time.Now().After(promise.Deadline()) {
+			return fmt.Errorf("%w: file %d missing from tree head of size %d after %s", ErrPromiseBroken, promise.Index, sth.TreeSize, promise.Deadline())
+		}
+		return ErrPromisePending
+	}
+
+	root, err := sth.Root()
+	if err != nil {
+		return err
+	}
+	proof, directions, err := c.GetMerkleProofAt(sth.TreeSize, promise.Index)
+	if err != nil {
+		return fmt.Errorf("fetching proof for file %d: %w", promise.Index, err)
+	}
+	if !merkle.VerifyProofAt(leaf, promise.Index, sth.TreeSize, proof, directions, root) {
+		return fmt.Errorf("%w: tree head of size %d does not include the promised file at index %d", ErrPromiseBroken, sth.TreeSize, promise.Index)
+	}
+	if sth.Time().After(promise.Deadline()) {
+		return fmt.Errorf("%w: file %d was included in a tree head signed at %s, after %s", ErrPromiseBroken, promise.Index, sth.Time(), promise.Deadline())
+	}
+	return nil
+}
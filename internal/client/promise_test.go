@@ -0,0 +1,74 @@
+package client
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+func TestInclusionPromise(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	srv := signedServer(private, "a")
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	client := NewClient(ts.URL)
+	response, err := client.uploadFile("b.txt", []byte("b"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	promise := response.Promise
+	if promise == nil || promise.Index != 1 {
+		t.Fatalf("expected a promise for index 1, got %+v", promise)
+	}
+
+	path := filepath.Join(t.TempDir(), "promises.json")
+	if err := savePromises(path, []*treehead.InclusionPromise{promise}); err != nil {
+		t.Fatal(err)
+	}
+	if err := savePromises(path, []*treehead.InclusionPromise{promise}); err != nil {
+		t.Fatal(err)
+	}
+	promises, err := LoadPromises(path)
+	if err != nil || len(promises) != 2 {
+		t.Fatalf("expected 2 recorded promises, got %d (%v)", len(promises), err)
+	}
+
+	if err := client.CheckInclusionPromise(promises[0], public); err != nil {
+		t.Fatalf("expected promise to be kept, got %v", err)
+	}
+}
+
+func TestInclusionPromiseBroken(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	ts := httptest.NewServer(api.SetupRoutes(signedServer(private, "a", "b")))
+	defer ts.Close()
+	client := NewClient(ts.URL)
+
+	pending := treehead.Promise(private, [32]byte{1}, 2, time.Now(), time.Hour)
+	if err := client.CheckInclusionPromise(pending, public); !errors.Is(err, ErrPromisePending) {
+		t.Errorf("expected ErrPromisePending, got %v", err)
+	}
+
+	expired := treehead.Promise(private, [32]byte{1}, 2, time.Now().Add(-time.Hour), time.Minute)
+	if err := client.CheckInclusionPromise(expired, public); !errors.Is(err, ErrPromiseBroken) {
+		t.Errorf("expected ErrPromiseBroken for an expired promise, got %v", err)
+	}
+
+	late := treehead.Promise(private, merkle.CreateHash([]byte("a")), 0, time.Now().Add(-time.Hour), time.Minute)
+	if err := client.CheckInclusionPromise(late, public); !errors.Is(err, ErrPromiseBroken) {
+		t.Errorf("expected ErrPromiseBroken for a file included after the deadline, got %v", err)
+	}
+
+	replaced := treehead.Promise(private, [32]byte{1}, 1, time.Now(), time.Hour)
+	if err := client.CheckInclusionPromise(replaced, public); !errors.Is(err, ErrPromiseBroken) {
+		t.Errorf("expected ErrPromiseBroken for a different leaf at the index, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// maxNodeBatch matches the server's limit on node hashes per /tree/nodes request
+const maxNodeBatch = 256
+
+// RemoteTree reads node hashes of the server's tree over the API. It
+// implements merkle.NodeSource, so a local tree can be diffed against it.
+type RemoteTree struct {
+	client   *Client
+	treeSize int
+}
+
+type nodesResponse struct {
+	TreeSize int      `json:"treeSize"`
+	Hashes   []string `json:"hashes"`
+}
+
+// RemoteTree returns the server's tree as it was when it held treeSize files.
+// A treeSize of 0 pins the tree to the server's size at the first request.
+func (c *Client) RemoteTree(treeSize int) *RemoteTree {
+	return &RemoteTree{client: c, treeSize: treeSize}
+}
+
+// TreeSize implements merkle.NodeSource
+func (t *RemoteTree) TreeSize() (int, error) {
+	if t.treeSize == 0 {
+		response, err := t.fetch(0, nil)
+		if err != nil {
+			return 0, err
+		}
+		t.treeSize = response.TreeSize
+	}
+	return t.treeSize, nil
+}
+
+// NodeHashes implements merkle.NodeSource, splitting large requests into batches the server accepts
+func (t *RemoteTree) NodeHashes(level int, indices []int) ([][32]byte, error) {
+	if _, err := t.TreeSize(); err != nil {
+		return nil, err
+	}
+
+	var hashes [][32]byte
+	for start := 0; start < len(indices); start += maxNodeBatch {
+		batch := indices[start:min(start+maxNodeBatch, len(indices))]
+		response, err := t.fetch(level, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Hashes) != len(batch) {
+			return nil, fmt.Errorf("requested %d node hashes, got %d", len(batch), len(response.Hashes))
+		}
+		for _, encoded := range response.Hashes {
+			hash, err := hex.DecodeString(encoded)
+			if err != nil || len(hash) != 32 {
+				return nil, fmt.Errorf("invalid node hash %q", encoded)
+			}
+			hashes = append(hashes, [32]byte(hash))
+		}
+	}
+	return hashes, nil
+}
+
+func (t *RemoteTree) fetch(level int, indices []int) (*nodesResponse, error) {
+	query := url.Values{"level": {strconv.Itoa(level)}}
+	if t.treeSize > 0 {
+		query.Set("treeSize", strconv.Itoa(t.treeSize))
+	}
+	if len(indices) > 0 {
+		parts := make([]string, len(indices))
+		for i, index := range indices {
+			parts[i] = strconv.Itoa(index)
+		}
+		query.Set("indices", strings.Join(parts, ","))
+	}
+
+	resp, err := t.client.get(t.client.serverURL + "/tree/nodes?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch node hashes: %s", resp.Status)
+	}
+
+	var response nodesResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DiffWithServer builds a tree from the local files and returns the ranges of
+// file indices whose contents differ from the server's current tree.
+func (c *Client) DiffWithServer(files []string) ([]merkle.LeafRange, error) {
+	if err := c.checkTreeMode("diff"); err != nil {
+		return nil, err
+	}
+	var data [][]byte
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, content)
+	}
+
+	local := merkle.BuildTree(data, merkle.EncodeBytes)
+	return merkle.Diff(local, c.RemoteTree(0))
+}
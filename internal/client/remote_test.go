@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// nodeServer serves /tree/nodes from a local tree
+func nodeServer(tree *merkle.Tree[[]byte]) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		level, _ := strconv.Atoi(r.URL.Query().Get("level"))
+		var indices []int
+		if query := r.URL.Query().Get("indices"); query != "" {
+			for _, part := range strings.Split(query, ",") {
+				index, _ := strconv.Atoi(part)
+				indices = append(indices, index)
+			}
+		}
+		hashes, err := tree.NodeHashes(level, indices)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := nodesResponse{TreeSize: tree.Size()}
+		for _, hash := range hashes {
+			response.Hashes = append(response.Hashes, hex.EncodeToString(hash[:]))
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestRemoteTreeDiff(t *testing.T) {
+	var remote, local [][]byte
+	for i := 0; i < 600; i++ {
+		remote = append(remote, []byte{byte(i), byte(i >> 8)})
+		local = append(local, []byte{byte(i), byte(i >> 8)})
+	}
+	local[300] = []byte("local change")
+	ts := nodeServer(merkle.BuildTree(remote, merkle.EncodeBytes))
+	defer ts.Close()
+
+	ranges, err := merkle.Diff(merkle.BuildTree(local, merkle.EncodeBytes), NewClient(ts.URL).RemoteTree(0))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []merkle.LeafRange{{Start: 300, End: 301}}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("expected %v, got %v", want, ranges)
+	}
+}
+
+func TestDiffWithServer(t *testing.T) {
+	srv := server.NewServer()
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 6; i++ {
+		content := []byte{byte(i)}
+		srv.UploadFile("", content)
+
+		if i == 2 {
+			content = []byte("local change")
+		}
+		path := filepath.Join(dir, string(rune('a'+i)))
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	client := NewClient(ts.URL)
+	ranges, err := client.DiffWithServer(files)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []merkle.LeafRange{{Start: 2, End: 3}}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("expected %v, got %v", want, ranges)
+	}
+
+	ranges, err = client.DiffWithServer(files[:4])
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []merkle.LeafRange{{Start: 2, End: 3}, {Start: 4, End: 6}}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("expected %v, got %v", want, ranges)
+	}
+}
+
+func TestMMRServer(t *testing.T) {
+	srv, _ := server.NewServerWithTreeMode(server.TreeModeMMR)
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+	defer os.Remove("root_hash.txt")
+	defer os.Remove(ChunkRootsFile)
+
+	dir := t.TempDir()
+	var files []string
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		if err := os.WriteFile(path, []byte{byte(i)}, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	client := NewClient(ts.URL)
+	root, err := client.UploadFiles(files)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := srv.GetMerkleRootHash(); root != hex.EncodeToString(want[:]) {
+		t.Errorf("expected the upload root to match the server's %x, got %s", want, root)
+	}
+	if _, err := client.DownloadAndVerifyFile(0); err != nil {
+		t.Errorf("expected the file to verify against the MMR root, got %v", err)
+	}
+	if _, err := client.DiffWithServer(files); !errors.Is(err, ErrUnsupportedTreeMode) {
+		t.Errorf("expected ErrUnsupportedTreeMode, got %v", err)
+	}
+}
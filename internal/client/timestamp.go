@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// Timestamp submits the SHA-256 hash of data to the server's timestamping
+// service and returns the receipt. Only the hash leaves the client.
+func (c *Client) Timestamp(data []byte) (*treehead.TimestampReceipt, error) {
+	hash := merkle.CreateHash(data)
+	body, err := json.Marshal(map[string]string{"hash": hex.EncodeToString(hash[:])})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(c.serverURL+"/timestamp", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp request failed: %s", resp.Status)
+	}
+
+	var receipt treehead.TimestampReceipt
+	if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+		return nil, err
+	}
+	if receipt.Hash != hex.EncodeToString(hash[:]) {
+		return nil, errors.New("receipt is for a different hash")
+	}
+	return &receipt, nil
+}
+
+// SaveReceipt writes a timestamp receipt to path
+func SaveReceipt(path string, receipt *treehead.TimestampReceipt) error {
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadReceipt reads a timestamp receipt written by SaveReceipt
+func LoadReceipt(path string) (*treehead.TimestampReceipt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var receipt treehead.TimestampReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, fmt.Errorf("decoding receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+// VerifyTimestamp checks offline that receipt covers data and is signed by
+// key, returning the time by which data existed
+func VerifyTimestamp(receipt *treehead.TimestampReceipt, data []byte, key ed25519.PublicKey) (time.Time, error) {
+	hash, err := receipt.DocumentHash()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if hash != merkle.CreateHash(data) {
+		return time.Time{}, errors.New("receipt is for a different document")
+	}
+	return receipt.Verify(key)
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/api"
+)
+
+func TestTimestampAndVerify(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	srv := signedServer(private, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.RunEpochs(ctx, 10*time.Millisecond)
+
+	ts := httptest.NewServer(api.SetupRoutes(srv))
+	defer ts.Close()
+
+	document := []byte("contract v1")
+	receipt, err := NewClient(ts.URL).Timestamp(document)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "receipt.json")
+	if err := SaveReceipt(path, receipt); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close() // Receipts verify offline
+
+	loaded, err := LoadReceipt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, err := VerifyTimestamp(loaded, document, public)
+	if err != nil {
+		t.Fatalf("expected receipt to verify, got %v", err)
+	}
+	if time.Since(at) > time.Minute {
+		t.Fatalf("expected a recent timestamp, got %s", at)
+	}
+
+	if _, err := VerifyTimestamp(loaded, []byte("contract v2"), public); err == nil {
+		t.Fatal("expected error for a different document")
+	}
+}
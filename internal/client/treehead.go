@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+type consistencyResponse struct {
+	Proof []string `json:"proof"`
+}
+
+type versionedProofResponse struct {
+	Proof      [][32]byte `json:"proof"`
+	Directions []bool     `json:"directions"`
+}
+
+// GetSignedTreeHead fetches the server's current signed tree head. The
+// signature is not checked; callers verify it against the key they trust.
+func (c *Client) GetSignedTreeHead() (*treehead.SignedTreeHead, error) {
+	var sth treehead.SignedTreeHead
+	if err := c.getJSON("/root", &sth); err != nil {
+		return nil, err
+	}
+	return &sth, nil
+}
+
+// GetConsistencyProof fetches a proof that the tree of size from is a prefix of the tree of size to
+func (c *Client) GetConsistencyProof(from, to int) ([][32]byte, error) {
+	var response consistencyResponse
+	if err := c.getJSON(fmt.Sprintf("/consistency?from=%d&to=%d", from, to), &response); err != nil {
+		return nil, err
+	}
+
+	proof := make([][32]byte, len(response.Proof))
+	for i, encoded := range response.Proof {
+		hash, err := hex.DecodeString(encoded)
+		if err != nil || len(hash) != 32 {
+			return nil, fmt.Errorf("invalid proof hash %q", encoded)
+		}
+		proof[i] = [32]byte(hash)
+	}
+	return proof, nil
+}
+
+// GetMerkleProofAt fetches an inclusion proof for fileIndex against the tree of treeSize files
+func (c *Client) GetMerkleProofAt(treeSize, fileIndex int) ([][32]byte, []bool, error) {
+	var response versionedProofResponse
+	if err := c.getJSON(fmt.Sprintf("/proof/%d?treeSize=%d", fileIndex, treeSize), &response); err != nil {
+		return nil, nil, err
+	}
+	return response.Proof, response.Directions, nil
+}
+
+func (c *Client) getJSON(path string, v interface{}) error {
+	resp, err := c.get(c.serverURL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,152 @@
+package replication
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/client"
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// ErrFork is returned when the leader presents a tree that does not extend
+// the follower's copy. A follower that sees a fork stops replicating.
+var ErrFork = errors.New("leader tree is not an extension of the local tree")
+
+// Follower keeps a local server in sync with a leader by pulling files the
+// leader has appended since the last sync.
+type Follower struct {
+	leader    *client.Client
+	leaderKey ed25519.PublicKey
+	local     *server.Server
+	// Alert is called when a fork is detected; it defaults to logging
+	Alert func(err error)
+}
+
+// NewFollower creates a follower replicating from the server at leaderURL,
+// whose tree heads must be signed by leaderKey.
+func NewFollower(leaderURL string, leaderKey ed25519.PublicKey, local *server.Server) *Follower {
+	return &Follower{
+		leader:    client.NewClient(leaderURL),
+		leaderKey: leaderKey,
+		local:     local,
+		Alert: func(err error) {
+			log.Printf("REPLICATION ALERT: %v", err)
+		},
+	}
+}
+
+// SetAuthToken sets the API key or token sent to a leader that requires
+// authentication; it needs the read scope
+func (f *Follower) SetAuthToken(token string) {
+	f.leader.AuthToken = token
+}
+
+// Run syncs every interval until ctx is cancelled or a fork is detected.
+// Transient errors such as an unreachable leader are logged and retried.
+func (f *Follower) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := f.Sync()
+		if errors.Is(err, ErrFork) {
+			f.Alert(err)
+			return err
+		}
+		if err != nil {
+			log.Printf("Replication sync failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the leader's signed tree head, checks that it extends the
+// local tree and pulls every missing file, verifying each one against the
+// signed root before it is stored.
+func (f *Follower) Sync() error {
+	sth, err := f.leader.GetSignedTreeHead()
+	if err != nil {
+		return fmt.Errorf("fetching tree head: %w", err)
+	}
+	// A bad signature means a wrong or rotated key rather than a fork, so it is retried
+	if err := sth.Verify(f.leaderKey); err != nil {
+		return fmt.Errorf("verifying tree head: %w", err)
+	}
+	root, err := sth.Root()
+	if err != nil {
+		return err
+	}
+
+	localSize := f.local.GetFileCount()
+	if err := f.checkConsistency(sth, localSize, root); err != nil {
+		return err
+	}
+	if sth.TreeSize == localSize {
+		return nil
+	}
+
+	// Files are verified one by one but added to the tree in a single epoch.
+	// When a download fails the batch is dropped and pulled again on the next sync.
+	files := make([][]byte, 0, sth.TreeSize-localSize)
+	for index := localSize; index < sth.TreeSize; index++ {
+		data, err := f.leader.DownloadFile(index)
+		if err != nil {
+			return fmt.Errorf("fetching file %d: %w", index, err)
+		}
+		proof, directions, err := f.leader.GetMerkleProofAt(sth.TreeSize, index)
+		if err != nil {
+			return fmt.Errorf("fetching proof for file %d: %w", index, err)
+		}
+		if !merkle.VerifyProofAt(merkle.CreateHash(data), index, sth.TreeSize, proof, directions, root) {
+			return fmt.Errorf("%w: file %d is not included in the signed tree head", ErrFork, index)
+		}
+		files = append(files, data)
+	}
+	if err := f.local.AppendFiles(files); err != nil {
+		return fmt.Errorf("storing files: %w", err)
+	}
+
+	if f.local.GetMerkleRootHash() != root {
+		return fmt.Errorf("%w: local root after sync does not match the signed tree head", ErrFork)
+	}
+	log.Printf("Replicated %d files, tree size %d", sth.TreeSize-localSize, sth.TreeSize)
+	return nil
+}
+
+// checkConsistency verifies that the leader's tree extends the local tree of localSize files
+func (f *Follower) checkConsistency(sth *treehead.SignedTreeHead, localSize int, root [32]byte) error {
+	if localSize == 0 {
+		return nil
+	}
+	if sth.TreeSize < localSize {
+		return fmt.Errorf("%w: leader tree size %d is smaller than local size %d", ErrFork, sth.TreeSize, localSize)
+	}
+
+	localRoot := f.local.GetMerkleRootHash()
+	if sth.TreeSize == localSize {
+		if root != localRoot {
+			return fmt.Errorf("%w: leader root differs at size %d", ErrFork, localSize)
+		}
+		return nil
+	}
+
+	proof, err := f.leader.GetConsistencyProof(localSize, sth.TreeSize)
+	if err != nil {
+		return fmt.Errorf("fetching consistency proof: %w", err)
+	}
+	if !merkle.VerifyConsistency(localSize, sth.TreeSize, localRoot, root, proof) {
+		return fmt.Errorf("%w: consistency proof from %d to %d does not verify", ErrFork, localSize, sth.TreeSize)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package replication
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/api"
+	"github.com/akhilesharora/go-merkle/internal/server"
+)
+
+func newLeader(t *testing.T, files ...string) (*server.Server, *httptest.Server, ed25519.PublicKey) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	leader := server.NewServer()
+	leader.SigningKey = private
+	for _, file := range files {
+		leader.UploadFile(file, []byte(file))
+	}
+	ts := httptest.NewServer(api.SetupRoutes(leader))
+	t.Cleanup(ts.Close)
+	return leader, ts, public
+}
+
+func TestFollowerSync(t *testing.T) {
+	leader, ts, key := newLeader(t, "a", "b", "c")
+	local := server.NewServer()
+	follower := NewFollower(ts.URL, key, local)
+
+	if err := follower.Sync(); err != nil {
+		t.Fatalf("Sync: Unexpected error: %v", err)
+	}
+	if local.GetFileCount() != 3 || local.GetMerkleRootHash() != leader.GetMerkleRootHash() {
+		t.Fatal("Sync: Follower doesn't match the leader after the first sync")
+	}
+
+	leader.UploadFile("d", []byte("d"))
+	leader.UploadFile("e", []byte("e"))
+	if err := follower.Sync(); err != nil {
+		t.Fatalf("Sync: Unexpected error: %v", err)
+	}
+	if local.GetFileCount() != 5 || local.GetMerkleRootHash() != leader.GetMerkleRootHash() {
+		t.Error("Sync: Follower doesn't match the leader after the leader grew")
+	}
+
+	// Nothing new to pull
+	if err := follower.Sync(); err != nil {
+		t.Errorf("Sync: Unexpected error: %v", err)
+	}
+}
+
+func TestFollowerDetectsFork(t *testing.T) {
+	_, ts, key := newLeader(t, "a", "x", "c", "d")
+
+	local := server.NewServer()
+	local.AppendFile([]byte("a"))
+	local.AppendFile([]byte("b"))
+
+	var alerted error
+	follower := NewFollower(ts.URL, key, local)
+	follower.Alert = func(err error) { alerted = err }
+
+	err := follower.Run(context.Background(), time.Millisecond)
+	if !errors.Is(err, ErrFork) {
+		t.Fatalf("Run: Expected ErrFork, got %v", err)
+	}
+	if alerted == nil {
+		t.Error("Run: Expected the fork to be alerted")
+	}
+	if local.GetFileCount() != 2 {
+		t.Error("Run: Follower must not pull files from a forked leader")
+	}
+}
+
+func TestFollowerRejectsRewind(t *testing.T) {
+	_, ts, key := newLeader(t, "a")
+
+	local := server.NewServer()
+	local.AppendFile([]byte("a"))
+	local.AppendFile([]byte("b"))
+
+	if err := NewFollower(ts.URL, key, local).Sync(); !errors.Is(err, ErrFork) {
+		t.Errorf("Sync: Expected ErrFork for a leader smaller than the follower, got %v", err)
+	}
+}
+
+func TestFollowerRejectsUnknownKey(t *testing.T) {
+	_, ts, _ := newLeader(t, "a")
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+
+	err := NewFollower(ts.URL, otherKey, server.NewServer()).Sync()
+	if err == nil {
+		t.Fatal("Sync: Expected error for a tree head signed by another key, got nil")
+	}
+	// A wrong or rotated key is a configuration problem, not a fork
+	if errors.Is(err, ErrFork) {
+		t.Errorf("Sync: Expected a signature error rather than ErrFork, got %v", err)
+	}
+}
+
+func TestFollowerDropsPartialBatch(t *testing.T) {
+	leader, _, key := newLeader(t, "a", "b", "c")
+	routes := api.SetupRoutes(leader)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/download/2" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		routes.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	local := server.NewServer()
+	if err := NewFollower(ts.URL, key, local).Sync(); err == nil || errors.Is(err, ErrFork) {
+		t.Fatalf("Sync: Expected a fetch error, got %v", err)
+	}
+	if local.GetFileCount() != 0 {
+		t.Errorf("Sync: Expected the partial batch to be dropped, got %d files", local.GetFileCount())
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// ChunkProof is one chunk of a file with its proof against the file's chunk tree
+type ChunkProof struct {
+	Index      int
+	Data       []byte
+	Proof      [][32]byte
+	Directions []bool
+}
+
+// AuditFile answers a storage audit: it returns the requested chunks of the
+// file with proofs against merkle.BuildChunkTree of the file. The file is
+// read from the Store when there is one, so the audit covers what is on disk.
+func (s *Server) AuditFile(fileIndex int, chunks []int) ([]ChunkProof, error) {
+	data, err := s.storedFile(fileIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := merkle.SplitChunks(data)
+	tree := merkle.BuildChunkTree(data)
+	proofs := make([]ChunkProof, len(chunks))
+	for i, chunk := range chunks {
+		if chunk < 0 || chunk >= len(parts) {
+			return nil, fmt.Errorf("chunk index %d out of range", chunk)
+		}
+		proof, directions, err := tree.GenerateProof(chunk)
+		if err != nil {
+			return nil, err
+		}
+		proofs[i] = ChunkProof{Index: chunk, Data: parts[chunk], Proof: proof, Directions: directions}
+	}
+	return proofs, nil
+}
+
+// storedFile reads a sealed file like readStored, refusing quarantined files
+func (s *Server) storedFile(fileIndex int) ([]byte, error) {
+	if s.isQuarantined(fileIndex) {
+		return nil, ErrQuarantined
+	}
+	return s.readStored(fileIndex)
+}
+
+// readStored reads a sealed file from the Store, or from memory without one
+func (s *Server) readStored(fileIndex int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if fileIndex < 0 || fileIndex >= len(s.Files) {
+		return nil, fmt.Errorf("file index out of range")
+	}
+	if s.Store == nil {
+		return s.Files[fileIndex], nil
+	}
+	// The store is read under the lock because uploads write to it
+	return s.Store.Get(fileIndex)
+}
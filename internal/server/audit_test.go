@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestAuditFile(t *testing.T) {
+	store, _ := storage.NewDiskStore(filepath.Join(t.TempDir(), "files"))
+	server := NewServer()
+	if err := server.Restore(store, "", merkle.VerifyFull); err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("0123456789"), merkle.ChunkSize/2)
+	if _, err := server.UploadFile("test.txt", data); err != nil {
+		t.Fatal(err)
+	}
+
+	root := merkle.BuildChunkTree(data).Root.Hash
+	chunks := merkle.SplitChunks(data)
+	proofs, err := server.AuditFile(0, []int{4, 0})
+	if err != nil {
+		t.Fatalf("AuditFile: Unexpected error: %v", err)
+	}
+	for i, want := range []int{4, 0} {
+		proof := proofs[i]
+		if proof.Index != want || !bytes.Equal(proof.Data, chunks[want]) {
+			t.Errorf("AuditFile: Expected chunk %d, got chunk %d", want, proof.Index)
+		}
+		if !merkle.VerifyProofAt(merkle.CreateHash(proof.Data), proof.Index, len(chunks), proof.Proof, proof.Directions, root) {
+			t.Errorf("AuditFile: Proof for chunk %d does not verify", want)
+		}
+	}
+
+	if _, err := server.AuditFile(0, []int{len(chunks)}); err == nil {
+		t.Error("AuditFile: Expected error for a chunk out of range")
+	}
+	if _, err := server.AuditFile(1, []int{0}); err == nil {
+		t.Error("AuditFile: Expected error for a file out of range")
+	}
+}
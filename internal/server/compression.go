@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// GetFileGzip returns the gzip stream the store holds for a file, so it can
+// be served without recompressing it. It returns false when the file is not
+// stored compressed. The stream is checked against the file's leaf hash
+// first; a stream that doesn't match quarantines the file.
+func (s *Server) GetFileGzip(fileIndex int) ([]byte, bool, error) {
+	if s.isQuarantined(fileIndex) {
+		return nil, false, ErrQuarantined
+	}
+
+	s.mu.RLock()
+	if fileIndex < 0 || fileIndex >= len(s.Files) {
+		s.mu.RUnlock()
+		return nil, false, fmt.Errorf("file index out of range")
+	}
+	store, ok := s.Store.(storage.GzipReader)
+	if !ok {
+		s.mu.RUnlock()
+		return nil, false, nil
+	}
+	compressed, ok, err := store.GetGzip(fileIndex)
+	leaf, leafErr := s.leafHash(fileIndex)
+	s.mu.RUnlock()
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	if leafErr != nil {
+		return nil, false, leafErr
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err == nil {
+		var data []byte
+		data, err = io.ReadAll(zr)
+		if err == nil && merkle.CreateHash(data) != leaf {
+			err = errors.New("stored content does not match the leaf hash")
+		}
+	}
+	if err != nil {
+		s.quarantine(fileIndex, fmt.Sprintf("serving stored gzip stream: %v", err))
+		return nil, false, ErrQuarantined
+	}
+	return compressed, true, nil
+}
+
+// CompressionStats reports how many bytes compression saves in the store;
+// it is empty unless the store is a storage.CompressedStore
+func (s *Server) CompressionStats() storage.CompressionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if store, ok := s.Store.(*storage.CompressedStore); ok {
+		return store.Stats()
+	}
+	return storage.CompressionStats{}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestCompressedStoreKeepsLeafHashes(t *testing.T) {
+	inner, _ := storage.NewDiskStore(filepath.Join(t.TempDir(), "files"))
+	server := NewServer()
+	if err := server.Restore(newCompressedStore(inner), "", merkle.VerifyFull); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(strings.Repeat("log line\n", 100))
+	server.UploadFile("app.log", data)
+
+	proof, directions, err := server.GenerateMerkleProof(0)
+	if err != nil || !merkle.VerifyProof(merkle.CreateHash(data), proof, directions, server.GetMerkleRootHash()) {
+		t.Fatalf("GenerateMerkleProof: Expected the leaf to be the hash of the uncompressed file (%v)", err)
+	}
+
+	compressed, ok, err := server.GetFileGzip(0)
+	if err != nil || !ok {
+		t.Fatalf("GetFileGzip: Expected a gzip stream, got %v (%v)", ok, err)
+	}
+	zr, _ := gzip.NewReader(bytes.NewReader(compressed))
+	if decompressed, _ := io.ReadAll(zr); !bytes.Equal(decompressed, data) {
+		t.Error("GetFileGzip: Stream does not decompress to the file")
+	}
+	if stats := server.CompressionStats(); stats.BytesSaved() <= 0 {
+		t.Errorf("CompressionStats: Expected bytes saved, got %+v", stats)
+	}
+	if _, ok, _ := NewServer().GetFileGzip(0); ok {
+		t.Error("GetFileGzip: Expected no gzip stream without a store")
+	}
+}
+
+func TestGetFileGzipChecksLeafHash(t *testing.T) {
+	dir := t.TempDir()
+	inner, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+	server := NewServer()
+	if err := server.Restore(newCompressedStore(inner), "", merkle.VerifyFull); err != nil {
+		t.Fatal(err)
+	}
+	server.UploadFile("app.log", []byte(strings.Repeat("log line\n", 100)))
+
+	// Replace the stored blob with a valid gzip stream of other content
+	other, _ := storage.NewDiskStore(filepath.Join(dir, "other"))
+	newCompressedStore(other).Put(0, []byte(strings.Repeat("tampered\n", 100)))
+	blob, _ := os.ReadFile(filepath.Join(dir, "other", "00000000.blob"))
+	os.WriteFile(filepath.Join(dir, "files", "00000000.blob"), blob, 0644)
+
+	if _, _, err := server.GetFileGzip(0); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("GetFileGzip: Expected ErrQuarantined for a stream that doesn't match the leaf, got %v", err)
+	}
+	if status := server.ScrubStatus(); len(status.Quarantined) != 1 {
+		t.Errorf("GetFileGzip: Expected the file to be quarantined, got %+v", status.Quarantined)
+	}
+}
+
+func newCompressedStore(inner storage.Store) *storage.CompressedStore {
+	store, _ := storage.NewCompressedStore(inner, "")
+	return store
+}
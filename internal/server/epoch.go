@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// Epoch describes the latest sealed batch of uploads. Epochs are numbered
+// from 1; every file belongs to the first epoch sealed after its upload.
+type Epoch struct {
+	Number   int                      `json:"epoch"`
+	TreeSize int                      `json:"treeSize"`
+	TreeHead *treehead.SignedTreeHead `json:"treeHead,omitempty"`
+}
+
+// enqueue stores data under the next free index and queues it for the next seal
+func (s *Server) enqueue(data []byte) (uint, error) {
+	index := len(s.Files) + len(s.pending)
+	if s.Store != nil {
+		if err := s.Store.Put(index, data); err != nil {
+			return 0, fmt.Errorf("storing file: %w", err)
+		}
+	}
+	s.pending = append(s.pending, data)
+	s.usedBytes += int64(len(data))
+	return uint(index), nil
+}
+
+// seal adds every queued file to the tree as one epoch and wakes WaitForEpoch callers
+func (s *Server) seal() {
+	if len(s.pending) == 0 {
+		return
+	}
+
+	for _, data := range s.pending {
+		hash := merkle.CreateHash(data)
+		s.Files = append(s.Files, data)
+		if s.MountainRange != nil {
+			s.MountainRange.AppendHash(hash)
+		} else {
+			s.appendVersionHash(hash)
+		}
+	}
+	s.pending = nil
+	s.epochSizes = append(s.epochSizes, len(s.Files))
+
+	// Batched epochs publish their tree head as they are sealed
+	if s.BatchUploads && s.SigningKey != nil {
+		s.signedTreeHead()
+	}
+	if s.epochSealed != nil {
+		close(s.epochSealed)
+		s.epochSealed = nil
+	}
+}
+
+// Seal closes the current epoch: queued uploads and timestamp hashes are
+// added to the tree, a tree head is signed and every waiting Timestamp
+// caller gets its receipt
+func (s *Server) Seal() {
+	s.timestampMu.Lock()
+	batch := s.timestamps
+	s.timestamps = nil
+	s.timestampMu.Unlock()
+
+	results := make([]timestampResult, len(batch))
+	s.mu.Lock()
+	indices := make([]int, len(batch))
+	for i, pending := range batch {
+		// Timestamps are admitted like uploads when they are added to the tree
+		if s.ReadOnly {
+			results[i].err = ErrReadOnly
+			continue
+		}
+		if err := s.checkQuota(len(pending.hash)); err != nil {
+			results[i].err = err
+			continue
+		}
+		index, err := s.enqueue(pending.hash[:])
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		indices[i] = int(index)
+	}
+	s.seal()
+
+	if len(batch) > 0 {
+		sth, err := s.signedTreeHead()
+		for i, pending := range batch {
+			if results[i].err != nil {
+				continue
+			}
+			if err != nil {
+				results[i].err = err
+				continue
+			}
+			proof, directions, err := s.generateMerkleProofAt(sth.TreeSize, indices[i])
+			if err != nil {
+				results[i].err = err
+				continue
+			}
+			results[i].receipt = treehead.NewTimestampReceipt(pending.hash, indices[i], proof, directions, sth)
+		}
+	}
+	s.mu.Unlock()
+
+	for i, pending := range batch {
+		pending.done <- results[i]
+	}
+}
+
+// RunEpochs calls Seal every interval until ctx is cancelled
+func (s *Server) RunEpochs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Seal()
+		}
+	}
+}
+
+// EpochOf returns the epoch the file at fileIndex was or will be sealed in
+func (s *Server) EpochOf(fileIndex int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if fileIndex < 0 || fileIndex >= len(s.Files)+len(s.pending) {
+		return 0, fmt.Errorf("file index out of range")
+	}
+	if fileIndex >= len(s.Files) {
+		return len(s.epochSizes) + 1, nil
+	}
+	return sort.Search(len(s.epochSizes), func(i int) bool { return s.epochSizes[i] > fileIndex }) + 1, nil
+}
+
+// WaitForEpoch blocks until the given epoch has been sealed or ctx is done,
+// and returns the latest sealed epoch. When ctx ends first the latest epoch
+// is returned along with the context's error.
+func (s *Server) WaitForEpoch(ctx context.Context, epoch int) (*Epoch, error) {
+	for {
+		s.mu.Lock()
+		if len(s.epochSizes) >= epoch {
+			defer s.mu.Unlock()
+			return s.currentEpoch(), nil
+		}
+		if s.epochSealed == nil {
+			s.epochSealed = make(chan struct{})
+		}
+		sealed := s.epochSealed
+		s.mu.Unlock()
+
+		select {
+		case <-sealed:
+		case <-ctx.Done():
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.currentEpoch(), ctx.Err()
+		}
+	}
+}
+
+// currentEpoch describes the latest sealed epoch
+func (s *Server) currentEpoch() *Epoch {
+	epoch := &Epoch{Number: len(s.epochSizes), TreeSize: len(s.Files)}
+	if sth, err := s.signedTreeHead(); err == nil {
+		epoch.TreeHead = sth
+	}
+	return epoch
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestBatchUploads(t *testing.T) {
+	store, _ := storage.NewDiskStore(t.TempDir())
+	server := NewServer()
+	server.Store = store
+	_, server.SigningKey, _ = ed25519.GenerateKey(nil)
+	server.BatchUploads = true
+
+	var files [][]byte
+	for i := 0; i < 3; i++ {
+		data := []byte{byte(i)}
+		files = append(files, data)
+		index, err := server.UploadFile("test.txt", data)
+		if err != nil || index != uint(i) {
+			t.Fatalf("UploadFile: Expected index %d, got %d (%v)", i, index, err)
+		}
+	}
+
+	if server.GetFileCount() != 0 {
+		t.Errorf("GetFileCount: Queued uploads should not be in the tree yet, got %d", server.GetFileCount())
+	}
+	if store.Len() != 3 {
+		t.Errorf("UploadFile: Queued uploads should already be stored, store holds %d", store.Len())
+	}
+	if epoch, _ := server.EpochOf(2); epoch != 1 {
+		t.Errorf("EpochOf: Expected pending epoch 1, got %d", epoch)
+	}
+	if _, err := server.IssueInclusionPromise(2); err != nil {
+		t.Errorf("IssueInclusionPromise: Unexpected error for a queued upload: %v", err)
+	}
+
+	done := make(chan *Epoch)
+	go func() {
+		epoch, _ := server.WaitForEpoch(context.Background(), 1)
+		done <- epoch
+	}()
+	time.Sleep(10 * time.Millisecond)
+	server.Seal()
+
+	epoch := <-done
+	if epoch.Number != 1 || epoch.TreeSize != 3 || epoch.TreeHead == nil {
+		t.Fatalf("WaitForEpoch: Unexpected epoch %+v", epoch)
+	}
+	want := merkle.BuildTree(files, merkle.EncodeBytes).Root.Hash
+	if server.GetMerkleRootHash() != want {
+		t.Error("Seal: Root hash doesn't match the uploaded files")
+	}
+	if root, _ := epoch.TreeHead.Root(); root != want {
+		t.Error("Seal: Published tree head doesn't match the sealed tree")
+	}
+
+	server.UploadFile("test.txt", []byte("next"))
+	if epoch, _ := server.EpochOf(0); epoch != 1 {
+		t.Errorf("EpochOf: Expected sealed epoch 1, got %d", epoch)
+	}
+	if epoch, _ := server.EpochOf(3); epoch != 2 {
+		t.Errorf("EpochOf: Expected pending epoch 2, got %d", epoch)
+	}
+	if _, err := server.EpochOf(4); err == nil {
+		t.Error("EpochOf: Expected error for out of range index, got nil")
+	}
+}
+
+func TestMaxBatchSize(t *testing.T) {
+	server := NewServer()
+	server.BatchUploads = true
+	server.MaxBatchSize = 2
+
+	server.UploadFile("test.txt", []byte("a"))
+	if server.GetFileCount() != 0 {
+		t.Fatal("UploadFile: Epoch sealed before reaching MaxBatchSize")
+	}
+	server.UploadFile("test.txt", []byte("b"))
+	if server.GetFileCount() != 2 {
+		t.Fatalf("UploadFile: Expected the epoch to be sealed at MaxBatchSize, got %d files", server.GetFileCount())
+	}
+}
+
+func TestWaitForEpochTimeout(t *testing.T) {
+	server := NewServer()
+	server.UploadFile("test.txt", []byte("a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	epoch, err := server.WaitForEpoch(ctx, 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForEpoch: Expected the context error, got %v", err)
+	}
+	if epoch == nil || epoch.Number != 1 || epoch.TreeHead != nil {
+		t.Errorf("WaitForEpoch: Expected the latest epoch without a tree head, got %+v", epoch)
+	}
+}
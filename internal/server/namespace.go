@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+)
+
+var (
+	// ErrNamespaceExists is returned when creating a namespace whose name is taken
+	ErrNamespaceExists = errors.New("namespace already exists")
+	// ErrNoNamespace is returned for a namespace that doesn't exist
+	ErrNoNamespace = errors.New("namespace does not exist")
+)
+
+// namespaceName restricts names to ones safe in URLs and file paths
+var namespaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// NamespaceConfig is a namespace as saved in the namespaces file
+type NamespaceConfig struct {
+	Name       string `json:"name"`
+	QuotaBytes int64  `json:"quotaBytes"`
+}
+
+// NamespaceInfo describes a namespace for the admin API
+type NamespaceInfo struct {
+	NamespaceConfig
+	UsedBytes int64  `json:"usedBytes"`
+	Files     int    `json:"files"`
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// Namespaces holds independent servers by name, each with its own tree,
+// storage, quota and signing key. The list of namespaces and their quotas
+// is saved to a file so they are reopened on restart.
+type Namespaces struct {
+	path string
+	ctx  context.Context
+	open func(ctx context.Context, name string) (*Server, error)
+
+	mu      sync.RWMutex
+	servers map[string]*Server
+	// stops cancel the context each namespace was opened with
+	stops map[string]context.CancelFunc
+}
+
+// NewNamespaces reopens the namespaces listed in path, creating each server
+// with open. Background tasks open starts for a namespace should run until
+// the context it is given is done, which is at the latest when ctx is. An
+// empty path keeps the list in memory only.
+func NewNamespaces(ctx context.Context, path string, open func(ctx context.Context, name string) (*Server, error)) (*Namespaces, error) {
+	n := &Namespaces{path: path, ctx: ctx, open: open, servers: map[string]*Server{}, stops: map[string]context.CancelFunc{}}
+
+	configs, err := LoadNamespaceConfigs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		srv, err := n.openNamespace(config.Name)
+		if err != nil {
+			return nil, fmt.Errorf("opening namespace %s: %w", config.Name, err)
+		}
+		setQuota(srv, config.QuotaBytes)
+	}
+	return n, nil
+}
+
+// openNamespace opens a namespace under its own context and adds it; the caller holds n.mu
+func (n *Namespaces) openNamespace(name string) (*Server, error) {
+	ctx, stop := context.WithCancel(n.ctx)
+	srv, err := n.open(ctx, name)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	n.servers[name] = srv
+	n.stops[name] = stop
+	return srv, nil
+}
+
+// setQuota changes the quota of srv under its lock, since the goroutines open
+// started may already be serving uploads. Quotas only change under n.mu, so
+// the caller can read srv.QuotaBytes while holding n.mu.
+func setQuota(srv *Server, quotaBytes int64) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.QuotaBytes = quotaBytes
+}
+
+// LoadNamespaceConfigs reads the namespaces saved in path; a missing file means none
+func LoadNamespaceConfigs(path string) ([]NamespaceConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading namespaces: %w", err)
+	}
+
+	var configs []NamespaceConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("decoding namespaces %s: %w", path, err)
+	}
+	return configs, nil
+}
+
+// Create opens a new namespace with the given quota in bytes; 0 means no limit
+func (n *Namespaces) Create(name string, quotaBytes int64) (*Server, error) {
+	if !namespaceName.MatchString(name) {
+		return nil, fmt.Errorf("invalid namespace name %q: use up to 63 lowercase letters, digits and dashes", name)
+	}
+	if quotaBytes < 0 {
+		return nil, fmt.Errorf("quota must not be negative")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.servers[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceExists, name)
+	}
+	srv, err := n.openNamespace(name)
+	if err != nil {
+		return nil, err
+	}
+	setQuota(srv, quotaBytes)
+	if err := n.save(); err != nil {
+		// Stop the background tasks of the namespace that won't be kept
+		n.stops[name]()
+		delete(n.servers, name)
+		delete(n.stops, name)
+		return nil, err
+	}
+	return srv, nil
+}
+
+// Get returns the server of a namespace
+func (n *Namespaces) Get(name string) (*Server, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	srv, ok := n.servers[name]
+	return srv, ok
+}
+
+// SetQuota changes the quota of a namespace. Files already stored stay even
+// when they exceed the new quota; only new uploads are refused.
+func (n *Namespaces) SetQuota(name string, quotaBytes int64) error {
+	if quotaBytes < 0 {
+		return fmt.Errorf("quota must not be negative")
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	srv, ok := n.servers[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoNamespace, name)
+	}
+	previous := srv.QuotaBytes
+	setQuota(srv, quotaBytes)
+	if err := n.save(); err != nil {
+		setQuota(srv, previous)
+		return err
+	}
+	return nil
+}
+
+// Info describes one namespace
+func (n *Namespaces) Info(name string) (NamespaceInfo, bool) {
+	srv, ok := n.Get(name)
+	if !ok {
+		return NamespaceInfo{}, false
+	}
+	return namespaceInfo(name, srv), true
+}
+
+// List describes every namespace, sorted by name
+func (n *Namespaces) List() []NamespaceInfo {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	infos := make([]NamespaceInfo, 0, len(n.servers))
+	for name, srv := range n.servers {
+		infos = append(infos, namespaceInfo(name, srv))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Servers returns the server of every namespace
+func (n *Namespaces) Servers() []*Server {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	servers := make([]*Server, 0, len(n.servers))
+	for _, srv := range n.servers {
+		servers = append(servers, srv)
+	}
+	return servers
+}
+
+func namespaceInfo(name string, srv *Server) NamespaceInfo {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	info := NamespaceInfo{
+		NamespaceConfig: NamespaceConfig{Name: name, QuotaBytes: srv.QuotaBytes},
+		UsedBytes:       srv.usedBytes,
+		Files:           len(srv.Files),
+	}
+	if srv.SigningKey != nil {
+		info.PublicKey = hex.EncodeToString(srv.SigningKey.Public().(ed25519.PublicKey))
+	}
+	return info
+}
+
+// save writes the namespace list; the caller holds n.mu
+func (n *Namespaces) save() error {
+	if n.path == "" {
+		return nil
+	}
+	configs := make([]NamespaceConfig, 0, len(n.servers))
+	for name, srv := range n.servers {
+		configs = append(configs, NamespaceConfig{Name: name, QuotaBytes: srv.QuotaBytes})
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+
+	data, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFileAtomic(n.path, data); err != nil {
+		return fmt.Errorf("saving namespaces: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	server := NewServer()
+	server.QuotaBytes = 10
+	if _, err := server.UploadFile("a.txt", []byte("123456")); err != nil {
+		t.Fatalf("UploadFile: Unexpected error: %v", err)
+	}
+	if _, err := server.UploadFile("b.txt", []byte("123456")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("UploadFile: Expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := server.UploadFile("c.txt", []byte("1234")); err != nil {
+		t.Errorf("UploadFile: Expected a file filling the quota exactly to fit, got %v", err)
+	}
+	if used := server.UsedBytes(); used != 10 {
+		t.Errorf("UsedBytes: Expected 10, got %d", used)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "namespaces.json")
+	opened := map[string]int{}
+	open := func(ctx context.Context, name string) (*Server, error) {
+		opened[name]++
+		return NewServer(), nil
+	}
+
+	namespaces, err := NewNamespaces(context.Background(), path, open)
+	if err != nil {
+		t.Fatalf("NewNamespaces: Unexpected error: %v", err)
+	}
+	teamA, err := namespaces.Create("team-a", 0)
+	if err != nil {
+		t.Fatalf("Create: Unexpected error: %v", err)
+	}
+	if _, err := namespaces.Create("team-b", 100); err != nil {
+		t.Fatalf("Create: Unexpected error: %v", err)
+	}
+	if _, err := namespaces.Create("team-a", 0); !errors.Is(err, ErrNamespaceExists) {
+		t.Errorf("Create: Expected ErrNamespaceExists, got %v", err)
+	}
+	for _, name := range []string{"", "Team", "../etc", "-a"} {
+		if _, err := namespaces.Create(name, 0); err == nil {
+			t.Errorf("Create(%q): Expected error for an invalid name", name)
+		}
+	}
+
+	// Namespaces have independent trees
+	teamA.UploadFile("a.txt", []byte("only in team-a"))
+	teamB, _ := namespaces.Get("team-b")
+	if teamA.GetFileCount() != 1 || teamB.GetFileCount() != 0 {
+		t.Errorf("Expected 1 and 0 files, got %d and %d", teamA.GetFileCount(), teamB.GetFileCount())
+	}
+	if info, _ := namespaces.Info("team-a"); info.UsedBytes != 14 || info.Files != 1 {
+		t.Errorf("Info: Unexpected usage %+v", info)
+	}
+
+	if err := namespaces.SetQuota("team-a", 50); err != nil {
+		t.Fatalf("SetQuota: Unexpected error: %v", err)
+	}
+	if err := namespaces.SetQuota("team-c", 50); !errors.Is(err, ErrNoNamespace) {
+		t.Errorf("SetQuota: Expected ErrNoNamespace, got %v", err)
+	}
+
+	// The namespaces and their quotas are reopened from the file
+	reopened, err := NewNamespaces(context.Background(), path, open)
+	if err != nil {
+		t.Fatalf("NewNamespaces: Unexpected error on reopen: %v", err)
+	}
+	list := reopened.List()
+	if len(list) != 2 || list[0].Name != "team-a" || list[0].QuotaBytes != 50 || list[1].QuotaBytes != 100 {
+		t.Errorf("List: Unexpected namespaces after reopen: %+v", list)
+	}
+	if opened["team-a"] != 2 || opened["team-b"] != 2 {
+		t.Errorf("Expected every namespace opened twice, got %v", opened)
+	}
+}
+
+func TestCreateNamespaceStopsOnSaveFailure(t *testing.T) {
+	// The namespaces file can't be written inside a missing directory
+	path := filepath.Join(t.TempDir(), "missing", "namespaces.json")
+	var opened context.Context
+	namespaces, _ := NewNamespaces(context.Background(), path, func(ctx context.Context, name string) (*Server, error) {
+		opened = ctx
+		return NewServer(), nil
+	})
+
+	if _, err := namespaces.Create("team-a", 0); err == nil {
+		t.Fatal("Create: Expected error when the namespaces file can't be saved")
+	}
+	if opened.Err() == nil {
+		t.Error("Create: Expected the context of the discarded namespace to be cancelled")
+	}
+	if _, ok := namespaces.Get("team-a"); ok {
+		t.Error("Create: Expected the namespace not to be kept")
+	}
+}
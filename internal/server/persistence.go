@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// leafSamples is the number of stored files VerifySpotCheck rehashes on Restore
+const leafSamples = 64
+
+// snapshotUsage is saved next to the snapshot so that Restore knows the
+// total size of the files it covers without reading them
+type snapshotUsage struct {
+	TreeSize  int   `json:"treeSize"`
+	UsedBytes int64 `json:"usedBytes"`
+}
+
+// Restore attaches a store to the server and loads the files it already
+// holds. Leaf hashes are read from the tree snapshot at snapshotPath when one
+// exists, so only files stored after the snapshot was written are rehashed.
+// VerifySpotCheck also rehashes a random sample of the stored files against
+// the snapshot's leaves, VerifyFull all of them. Restored files are read from
+// the store when requested rather than kept in memory.
+func (s *Server) Restore(store storage.Store, snapshotPath string, verify merkle.VerifyMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := store.Len()
+	tree, err := loadSnapshot(snapshotPath, verify)
+	if err != nil {
+		return err
+	}
+	if len(tree.Leaves) > count {
+		return fmt.Errorf("snapshot has %d leaves but the store holds %d files", len(tree.Leaves), count)
+	}
+
+	snapshotSize := len(tree.Leaves)
+	usedBytes, sized := loadUsage(snapshotPath, snapshotSize)
+	sampled := map[int]bool{}
+	if verify == merkle.VerifySpotCheck && len(tree.Leaves) > 0 {
+		for i := 0; i < leafSamples; i++ {
+			sampled[rand.Intn(len(tree.Leaves))] = true
+		}
+	}
+
+	hashes := make([][32]byte, 0, count)
+	for _, leaf := range tree.Leaves {
+		hashes = append(hashes, leaf.Hash)
+	}
+	for i := 0; i < count; i++ {
+		snapshotted := i < len(tree.Leaves)
+		if snapshotted && sized && verify != merkle.VerifyFull && !sampled[i] {
+			continue
+		}
+		data, err := store.Get(i)
+		if err != nil {
+			return fmt.Errorf("loading file %d: %w", i, err)
+		}
+		hash := merkle.CreateHash(data)
+		switch {
+		case !snapshotted:
+			// Files stored after the snapshot was taken still have to be hashed
+			hashes = append(hashes, hash)
+			usedBytes += int64(len(data))
+		case hash != tree.Leaves[i].Hash:
+			return fmt.Errorf("stored file %d doesn't match its leaf hash in the snapshot", i)
+		case !sized:
+			usedBytes += int64(len(data))
+		}
+	}
+	if len(tree.Leaves) < count {
+		tree = merkle.BuildTreeFromHashes(hashes, merkle.EncodeBytes)
+	}
+
+	s.Store = store
+	s.snapshotPath = snapshotPath
+	s.snapshotSize = snapshotSize
+	s.Files = make([][]byte, count)
+	s.pending = nil
+	s.usedBytes = usedBytes
+	s.epochSizes = nil
+	if count > 0 {
+		// Restored files count as one sealed epoch
+		s.epochSizes = []int{count}
+	}
+	s.Versions = nil
+	for _, leaf := range tree.Leaves {
+		if s.MountainRange != nil {
+			s.MountainRange.AppendHash(leaf.Hash)
+		} else {
+			s.appendVersionHash(leaf.Hash)
+		}
+	}
+	return nil
+}
+
+// SaveSnapshot writes the current tree to the snapshot path given to Restore
+func (s *Server) SaveSnapshot() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	// The snapshot holds every level, so the tree is only built out in full here
+	var buf bytes.Buffer
+	var err error
+	if s.MountainRange != nil {
+		tree := merkle.BuildTreeFromHashes(s.MountainRange.LeafHashes(), merkle.EncodeBytes)
+		err = merkle.WriteSnapshot(&buf, tree, merkle.SnapshotLeaves)
+	} else {
+		tree := merkle.BuildTreeFromHashes(s.latestVersion().LeafHashes(), merkle.EncodeBytes)
+		err = merkle.WriteSnapshot(&buf, tree, merkle.SnapshotFull)
+	}
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFileAtomic(s.snapshotPath, buf.Bytes()); err != nil {
+		return err
+	}
+
+	// Queued files are stored but not in the snapshot, so they are left out
+	usage := snapshotUsage{TreeSize: len(s.Files), UsedBytes: s.usedBytes}
+	for _, data := range s.pending {
+		usage.UsedBytes -= int64(len(data))
+	}
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	return storage.WriteFileAtomic(usagePath(s.snapshotPath), data)
+}
+
+// RunSnapshots saves the tree snapshot every interval until ctx is cancelled,
+// skipping intervals in which the tree hasn't grown since the last save. Files
+// stored after the last snapshot are rehashed by Restore, so after a crash
+// only those are.
+func (s *Server) RunSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.mu.RLock()
+	saved := s.snapshotSize
+	s.mu.RUnlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count := s.GetFileCount()
+			if count == saved {
+				continue
+			}
+			if err := s.SaveSnapshot(); err != nil {
+				log.Printf("Failed to save tree snapshot: %v", err)
+				continue
+			}
+			saved = count
+		}
+	}
+}
+
+// loadUsage reads the size of the files in a snapshot of treeSize leaves,
+// returning false when it isn't known
+func loadUsage(snapshotPath string, treeSize int) (int64, bool) {
+	if treeSize == 0 {
+		return 0, true
+	}
+	if snapshotPath == "" {
+		return 0, false
+	}
+	data, err := os.ReadFile(usagePath(snapshotPath))
+	if err != nil {
+		return 0, false
+	}
+	var usage snapshotUsage
+	if err := json.Unmarshal(data, &usage); err != nil || usage.TreeSize != treeSize {
+		return 0, false
+	}
+	return usage.UsedBytes, true
+}
+
+func usagePath(snapshotPath string) string {
+	return snapshotPath + ".usage"
+}
+
+// loadSnapshot reads the tree snapshot at path, returning an empty tree when there is none
+func loadSnapshot(path string, verify merkle.VerifyMode) (*merkle.Tree[[]byte], error) {
+	if path == "" {
+		return merkle.BuildTree(nil, merkle.EncodeBytes), nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return merkle.BuildTree(nil, merkle.EncodeBytes), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer f.Close()
+
+	tree, err := merkle.ReadSnapshot(f, merkle.EncodeBytes, verify)
+	if err != nil {
+		return nil, fmt.Errorf("loading snapshot %s: %w", path, err)
+	}
+	return tree, nil
+}
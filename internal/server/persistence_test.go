@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestRestoreFromSnapshot(t *testing.T) {
+	for _, mode := range []string{TreeModeBinary, TreeModeMMR} {
+		dir := t.TempDir()
+		snapshot := filepath.Join(dir, "tree.snapshot")
+
+		store, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+		server, _ := NewServerWithTreeMode(mode)
+		if err := server.Restore(store, snapshot, merkle.VerifySpotCheck); err != nil {
+			t.Fatalf("%s: Restore: Unexpected error on empty store: %v", mode, err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := server.UploadFile("test.txt", []byte{byte(i)}); err != nil {
+				t.Fatalf("%s: UploadFile: Unexpected error: %v", mode, err)
+			}
+		}
+		if err := server.SaveSnapshot(); err != nil {
+			t.Fatalf("%s: SaveSnapshot: Unexpected error: %v", mode, err)
+		}
+
+		// An upload after the snapshot must be picked up by rehashing only that file
+		if _, err := server.UploadFile("test.txt", []byte("late")); err != nil {
+			t.Fatalf("%s: UploadFile: Unexpected error: %v", mode, err)
+		}
+		wantRoot := server.GetMerkleRootHash()
+
+		store, _ = storage.NewDiskStore(filepath.Join(dir, "files"))
+		restored, _ := NewServerWithTreeMode(mode)
+		if err := restored.Restore(store, snapshot, merkle.VerifyFull); err != nil {
+			t.Fatalf("%s: Restore: Unexpected error: %v", mode, err)
+		}
+		if restored.GetFileCount() != 6 {
+			t.Errorf("%s: Restore: Expected 6 files, got %d", mode, restored.GetFileCount())
+		}
+		if restored.GetMerkleRootHash() != wantRoot {
+			t.Errorf("%s: Restore: Root hash doesn't match the server before restart", mode)
+		}
+		if _, err := restored.GetMerkleRootHashAt(3); err != nil {
+			t.Errorf("%s: Restore: Expected earlier versions to be available: %v", mode, err)
+		}
+	}
+}
+
+func TestRestoreRejectsSnapshotAheadOfStore(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "tree.snapshot")
+
+	server := NewServer()
+	server.UploadFile("test.txt", []byte("test"))
+	server.snapshotPath = snapshot
+	if err := server.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+	if err := NewServer().Restore(store, snapshot, merkle.VerifySpotCheck); err == nil {
+		t.Error("Restore: Expected error when the snapshot has more leaves than the store, got nil")
+	}
+}
+
+func TestRestoreEncryptedStoreAfterKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	keyring, _ := storage.LoadOrCreateKeyring(filepath.Join(dir, "keyring.json"))
+	open := func() *storage.EncryptedStore {
+		inner, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+		store, _ := storage.NewEncryptedStore(inner, keyring, filepath.Join(dir, "keys"))
+		return store
+	}
+
+	server := NewServer()
+	if err := server.Restore(open(), "", merkle.VerifyFull); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		server.UploadFile("test.txt", []byte{byte(i)})
+	}
+	wantRoot := server.GetMerkleRootHash()
+
+	keyring.Rotate()
+	if _, err := open().Rewrap(); err != nil {
+		t.Fatalf("Rewrap: Unexpected error: %v", err)
+	}
+	keyring.Retire()
+
+	// Without a snapshot every leaf is rehashed from the decrypted files
+	restored := NewServer()
+	if err := restored.Restore(open(), "", merkle.VerifyFull); err != nil {
+		t.Fatalf("Restore: Unexpected error: %v", err)
+	}
+	if restored.GetMerkleRootHash() != wantRoot {
+		t.Error("Restore: Root hash changed after key rotation")
+	}
+}
+
+func TestRestoreLoadsFilesLazily(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "tree.snapshot")
+
+	store, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+	server := NewServer()
+	server.Restore(store, snapshot, merkle.VerifySpotCheck)
+	server.UploadFile("test.txt", []byte("first"))
+	server.UploadFile("test.txt", []byte("second"))
+	if err := server.SaveSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _ = storage.NewDiskStore(filepath.Join(dir, "files"))
+	restored := NewServer()
+	if err := restored.Restore(store, snapshot, merkle.VerifySpotCheck); err != nil {
+		t.Fatalf("Restore: Unexpected error: %v", err)
+	}
+	if restored.Files[1] != nil {
+		t.Error("Restore: Expected restored files not to be held in memory")
+	}
+	data, err := restored.GetFileData(1)
+	if err != nil || string(data) != "second" {
+		t.Errorf("GetFileData: Expected %q from the store, got %q (%v)", "second", data, err)
+	}
+	if restored.UsedBytes() != server.UsedBytes() {
+		t.Errorf("Restore: Expected %d used bytes, got %d", server.UsedBytes(), restored.UsedBytes())
+	}
+
+	// New uploads extend the restored tree without rehashing the restored files
+	server.UploadFile("test.txt", []byte("third"))
+	restored.UploadFile("test.txt", []byte("third"))
+	if restored.GetMerkleRootHash() != server.GetMerkleRootHash() {
+		t.Error("UploadFile: Root hash after restore doesn't match the original server")
+	}
+}
+
+func TestRestoreDetectsCorruptedFile(t *testing.T) {
+	for _, verify := range []merkle.VerifyMode{merkle.VerifySpotCheck, merkle.VerifyFull} {
+		dir := t.TempDir()
+		snapshot := filepath.Join(dir, "tree.snapshot")
+
+		store, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+		server := NewServer()
+		server.Restore(store, snapshot, verify)
+		server.UploadFile("test.txt", []byte("original"))
+		if err := server.SaveSnapshot(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "files", "00000000.blob"), []byte("tampered"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		store, _ = storage.NewDiskStore(filepath.Join(dir, "files"))
+		if err := NewServer().Restore(store, snapshot, verify); err == nil {
+			t.Errorf("Restore(%d): Expected error for a stored file that doesn't match its leaf hash, got nil", verify)
+		}
+	}
+}
+
+func TestRunSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "tree.snapshot")
+	store, _ := storage.NewDiskStore(filepath.Join(dir, "files"))
+	server := NewServer()
+	server.Restore(store, snapshot, merkle.VerifySpotCheck)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.RunSnapshots(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	// The snapshot must not be written while the temporary directory is removed
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 0; i < 3; i++ {
+		server.UploadFile("test.txt", []byte{byte(i)})
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		tree, err := loadSnapshot(snapshot, merkle.VerifyFull)
+		if err == nil && len(tree.Leaves) == 3 {
+			if tree.Root.Hash != server.GetMerkleRootHash() {
+				t.Error("RunSnapshots: Snapshot root doesn't match the tree")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("RunSnapshots: Expected a snapshot of 3 leaves, got %d (%v)", len(tree.Leaves), err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
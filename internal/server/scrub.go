@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// ErrQuarantined is returned for files the scrubber found corrupt
+var ErrQuarantined = errors.New("file is quarantined: its stored content does not match its leaf hash")
+
+// QuarantinedFile is a file whose stored content failed a scrub
+type QuarantinedFile struct {
+	Index  int       `json:"index"`
+	Reason string    `json:"reason"`
+	Since  time.Time `json:"since"`
+}
+
+// ScrubStatus reports the progress and findings of the integrity scrubber
+type ScrubStatus struct {
+	FilesChecked uint64 `json:"filesChecked"`
+	BytesChecked uint64 `json:"bytesChecked"`
+	// Passes counts completed scans over every file
+	Passes      uint64            `json:"passes"`
+	LastPassAt  time.Time         `json:"lastPassAt"`
+	Quarantined []QuarantinedFile `json:"quarantined"`
+}
+
+// scrubState is the scrubber's bookkeeping, guarded by its own lock so
+// checks for quarantined files don't contend with uploads
+type scrubState struct {
+	mu          sync.Mutex
+	status      ScrubStatus
+	next        int
+	quarantined map[int]QuarantinedFile
+}
+
+// RunScrubber re-hashes one stored file after another at rate files per
+// second until ctx is cancelled, starting over after the last file.
+func (s *Server) RunScrubber(ctx context.Context, rate float64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.scrubNext()
+		}
+	}
+}
+
+// scrubNext checks the next file in scrub order
+func (s *Server) scrubNext() {
+	count := s.GetFileCount()
+	if count == 0 {
+		return
+	}
+
+	s.scrub.mu.Lock()
+	index := s.scrub.next % count
+	s.scrub.next = index + 1
+	if s.scrub.next == count {
+		s.scrub.next = 0
+		defer func() {
+			s.scrub.mu.Lock()
+			s.scrub.status.Passes++
+			s.scrub.status.LastPassAt = time.Now()
+			s.scrub.mu.Unlock()
+		}()
+	}
+	s.scrub.mu.Unlock()
+
+	s.ScrubFile(index)
+}
+
+// ScrubFile re-hashes the stored content of a file and compares it with the
+// file's leaf hash in the tree. A corrupt file is quarantined, and a
+// quarantined file that checks out again, for example after being restored
+// from a replica, is released. It reports whether the file is intact.
+func (s *Server) ScrubFile(fileIndex int) (bool, error) {
+	s.mu.RLock()
+	leaf, err := s.leafHash(fileIndex)
+	s.mu.RUnlock()
+	if err != nil {
+		return false, err
+	}
+
+	data, readErr := s.readStored(fileIndex)
+	reason := ""
+	switch {
+	case readErr != nil:
+		reason = fmt.Sprintf("reading stored file: %v", readErr)
+	case merkle.CreateHash(data) != leaf:
+		reason = "stored content does not match the leaf hash"
+	}
+
+	s.scrub.mu.Lock()
+	s.scrub.status.FilesChecked++
+	s.scrub.status.BytesChecked += uint64(len(data))
+	if reason == "" {
+		delete(s.scrub.quarantined, fileIndex)
+		s.scrub.mu.Unlock()
+		return true, nil
+	}
+	s.scrub.mu.Unlock()
+	s.quarantine(fileIndex, reason)
+	return false, nil
+}
+
+// quarantine refuses reads of a file until ScrubFile finds it intact again
+func (s *Server) quarantine(fileIndex int, reason string) {
+	s.scrub.mu.Lock()
+	defer s.scrub.mu.Unlock()
+	if _, ok := s.scrub.quarantined[fileIndex]; !ok {
+		if s.scrub.quarantined == nil {
+			s.scrub.quarantined = map[int]QuarantinedFile{}
+		}
+		s.scrub.quarantined[fileIndex] = QuarantinedFile{Index: fileIndex, Reason: reason, Since: time.Now()}
+	}
+}
+
+// ScrubStatus returns the scrubber's counters and the quarantined files
+func (s *Server) ScrubStatus() ScrubStatus {
+	s.scrub.mu.Lock()
+	defer s.scrub.mu.Unlock()
+
+	status := s.scrub.status
+	status.Quarantined = make([]QuarantinedFile, 0, len(s.scrub.quarantined))
+	for _, file := range s.scrub.quarantined {
+		status.Quarantined = append(status.Quarantined, file)
+	}
+	sort.Slice(status.Quarantined, func(i, j int) bool { return status.Quarantined[i].Index < status.Quarantined[j].Index })
+	return status
+}
+
+// isQuarantined reports whether the scrubber quarantined the file
+func (s *Server) isQuarantined(fileIndex int) bool {
+	s.scrub.mu.Lock()
+	defer s.scrub.mu.Unlock()
+	_, ok := s.scrub.quarantined[fileIndex]
+	return ok
+}
+
+// leafHash returns the hash the tree holds for a sealed file
+func (s *Server) leafHash(fileIndex int) ([32]byte, error) {
+	if fileIndex < 0 || fileIndex >= len(s.Files) {
+		return [32]byte{}, fmt.Errorf("file index out of range")
+	}
+	if s.MountainRange != nil {
+		return s.MountainRange.LeafHashes()[fileIndex], nil
+	}
+	return s.latestVersion().NodeHash(0, fileIndex)
+}
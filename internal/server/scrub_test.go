@@ -0,0 +1,64 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestScrubQuarantinesCorruptFiles(t *testing.T) {
+	for _, mode := range []string{TreeModeBinary, TreeModeMMR} {
+		dir := filepath.Join(t.TempDir(), "files")
+		store, _ := storage.NewDiskStore(dir)
+		server, _ := NewServerWithTreeMode(mode)
+		if err := server.Restore(store, "", merkle.VerifyFull); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"a", "b", "c"} {
+			server.UploadFile(file, []byte(file))
+		}
+
+		blob := filepath.Join(dir, "00000001.blob")
+		if err := os.WriteFile(blob, []byte("rotten"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			server.scrubNext()
+		}
+
+		status := server.ScrubStatus()
+		if status.FilesChecked != 3 || status.Passes != 1 || status.LastPassAt.IsZero() {
+			t.Errorf("%s: ScrubStatus: Expected one pass over 3 files, got %+v", mode, status)
+		}
+		if len(status.Quarantined) != 1 || status.Quarantined[0].Index != 1 {
+			t.Fatalf("%s: ScrubStatus: Expected file 1 quarantined, got %+v", mode, status.Quarantined)
+		}
+		if _, err := server.GetFileData(1); !errors.Is(err, ErrQuarantined) {
+			t.Errorf("%s: GetFileData: Expected ErrQuarantined, got %v", mode, err)
+		}
+		if _, err := server.AuditFile(1, []int{0}); !errors.Is(err, ErrQuarantined) {
+			t.Errorf("%s: AuditFile: Expected ErrQuarantined, got %v", mode, err)
+		}
+		if _, err := server.GetFileData(0); err != nil {
+			t.Errorf("%s: GetFileData: Unexpected error for an intact file: %v", mode, err)
+		}
+
+		// Repairing the blob and checking it again releases it
+		if err := os.WriteFile(blob, []byte("b"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if intact, err := server.ScrubFile(1); err != nil || !intact {
+			t.Errorf("%s: ScrubFile: Expected repaired file to be intact, got %v (%v)", mode, intact, err)
+		}
+		if len(server.ScrubStatus().Quarantined) != 0 {
+			t.Errorf("%s: ScrubStatus: Expected no quarantined files after repair", mode)
+		}
+		if _, err := server.ScrubFile(3); err == nil {
+			t.Errorf("%s: ScrubFile: Expected error for a file out of range", mode)
+		}
+	}
+}
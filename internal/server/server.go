@@ -1,66 +1,396 @@
 package server
 
 import (
+	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/akhilesharora/go-merkle/internal/storage"
 	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 )
 
 type ServerInterface interface {
-	UploadFile(filename string, data []byte) uint
+	UploadFile(filename string, data []byte) (uint, error)
 	GetFileData(fileIndex int) ([]byte, error)
 	GetFileCount() int
 	GetMerkleRootHash() [32]byte
 	GenerateMerkleProof(fileIndex int) ([][32]byte, []bool, error)
+	GetMerkleRootHashAt(treeSize int) ([32]byte, error)
+	GenerateMerkleProofAt(treeSize, fileIndex int) ([][32]byte, []bool, error)
+	GetNodeHashes(treeSize, level int, indices []int) ([][32]byte, error)
+	GetSignedTreeHead() (*treehead.SignedTreeHead, error)
+	GetConsistencyProof(oldSize, newSize int) ([][32]byte, error)
+	IssueInclusionPromise(fileIndex int) (*treehead.InclusionPromise, error)
+	Timestamp(ctx context.Context, hash [32]byte) (*treehead.TimestampReceipt, error)
+	EpochOf(fileIndex int) (int, error)
+	WaitForEpoch(ctx context.Context, epoch int) (*Epoch, error)
+	AuditFile(fileIndex int, chunks []int) ([]ChunkProof, error)
+	ScrubFile(fileIndex int) (bool, error)
+	ScrubStatus() ScrubStatus
+	GetFileGzip(fileIndex int) ([]byte, bool, error)
+	CompressionStats() storage.CompressionStats
+	TreeMode() string
 }
 
+// ErrReadOnly is returned by UploadFile on a server that only replicates another server's files
+var ErrReadOnly = errors.New("server is a read-only replica")
+
+// ErrNoSigningKey is returned by methods that sign when the server has no SigningKey
+var ErrNoSigningKey = errors.New("server has no signing key")
+
+// ErrQuotaExceeded is returned by UploadFile when the file would take the server over QuotaBytes
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// DefaultMaxMergeDelay is the MaxMergeDelay of servers created by NewServer
+const DefaultMaxMergeDelay = time.Minute
+
+// Tree structures the server can maintain over uploaded files
+const (
+	TreeModeBinary = "binary"
+	TreeModeMMR    = "mmr"
+)
+
 type Server struct {
-	MerkleTree *merkle.MerkleTree
-	Files      [][]byte
+	Files [][]byte
+	// Versions holds one persistent tree per upload; Versions[n-1] is the tree of
+	// the first n files. The versions share their nodes, so each upload only adds
+	// O(log N) of them, and the last one is the current tree.
+	Versions []*merkle.PersistentTree
+	// MountainRange replaces Versions when the server runs in TreeModeMMR
+	MountainRange *merkle.MountainRange
+	// Store persists uploaded files when set; see Restore
+	Store        storage.Store
+	snapshotPath string
+	// snapshotSize is the number of leaves in the snapshot Restore loaded
+	snapshotSize int
+	// SigningKey signs the tree heads served by GetSignedTreeHead and inclusion promises
+	SigningKey ed25519.PrivateKey
+	// MaxMergeDelay is the longest an uploaded file may take to appear in a signed tree head
+	MaxMergeDelay time.Duration
+	// ReadOnly rejects uploads; files are only added through AppendFile by replication
+	ReadOnly bool
+	// BatchUploads queues uploads until the next Seal instead of adding each one to the tree
+	BatchUploads bool
+	// MaxBatchSize seals the current epoch early once that many uploads are queued; 0 means no limit
+	MaxBatchSize int
+	// QuotaBytes limits the total size of uploaded files; 0 means no limit
+	QuotaBytes int64
+	// treeHead is the latest signed tree head, with any cosignatures collected for it
+	treeHead *treehead.SignedTreeHead
+
+	mu sync.RWMutex
+	// pending holds uploads stored but not yet added to the tree
+	pending [][]byte
+	// usedBytes is the total size of sealed and pending files
+	usedBytes int64
+	// epochSizes holds the tree size at the end of each sealed epoch
+	epochSizes []int
+	// epochSealed is closed when the next epoch is sealed
+	epochSealed chan struct{}
+
+	// scrub tracks the integrity scrubber and the files it quarantined
+	scrub scrubState
+
+	// timestamps are hashes waiting for the next Seal
+	timestamps  []pendingTimestamp
+	timestampMu sync.Mutex
 }
 
 func (s *Server) GetFileData(fileIndex int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	if fileIndex < 0 || fileIndex >= len(s.Files) {
 		return nil, fmt.Errorf("file index out of range")
 	}
-	return s.Files[fileIndex], nil
+	if s.isQuarantined(fileIndex) {
+		return nil, ErrQuarantined
+	}
+	return s.fileData(fileIndex)
 }
 
 func (s *Server) GetFileCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.Files)
 }
 
+// UsedBytes returns the total size of the files uploaded so far, including queued ones
+func (s *Server) UsedBytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.usedBytes
+}
+
 func NewServer() *Server {
 	return &Server{
-		MerkleTree: &merkle.MerkleTree{},
-		Files:      [][]byte{},
+		Files:         [][]byte{},
+		MaxMergeDelay: DefaultMaxMergeDelay,
+	}
+}
+
+// NewServerWithTreeMode creates a server maintaining the given tree structure
+func NewServerWithTreeMode(mode string) (*Server, error) {
+	switch mode {
+	case TreeModeBinary:
+		return NewServer(), nil
+	case TreeModeMMR:
+		return &Server{
+			Files:         [][]byte{},
+			MountainRange: &merkle.MountainRange{},
+			MaxMergeDelay: DefaultMaxMergeDelay,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown tree mode %q", mode)
+	}
+}
+
+// TreeMode returns the tree structure the server maintains, TreeModeBinary or TreeModeMMR
+func (s *Server) TreeMode() string {
+	if s.MountainRange != nil {
+		return TreeModeMMR
+	}
+	return TreeModeBinary
+}
+
+func (s *Server) UploadFile(filename string, data []byte) (uint, error) {
+	if s.ReadOnly {
+		return 0, ErrReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkQuota(len(data)); err != nil {
+		return 0, err
+	}
+	index, err := s.enqueue(data)
+	if err != nil {
+		return 0, err
 	}
+	if !s.BatchUploads || (s.MaxBatchSize > 0 && len(s.pending) >= s.MaxBatchSize) {
+		s.seal()
+	}
+	return index, nil
 }
 
-func (s *Server) UploadFile(filename string, data []byte) uint {
-	s.Files = append(s.Files, data)
-	s.updateMerkleTree()
-	return uint(len(s.Files) - 1)
+// checkQuota refuses size more bytes when they would take the server over
+// QuotaBytes; the caller holds s.mu
+func (s *Server) checkQuota(size int) error {
+	if s.QuotaBytes > 0 && s.usedBytes+int64(size) > s.QuotaBytes {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrQuotaExceeded, s.usedBytes, s.QuotaBytes)
+	}
+	return nil
+}
+
+// AppendFile stores data as the next file and adds it to the tree right
+// away, regardless of ReadOnly and BatchUploads. Queued uploads are sealed
+// in the same epoch.
+func (s *Server) AppendFile(data []byte) (uint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.enqueue(data)
+	if err != nil {
+		return 0, err
+	}
+	s.seal()
+	return index, nil
 }
 
-func (s *Server) updateMerkleTree() {
-	var files []merkle.File
-	for _, fileData := range s.Files {
-		files = append(files, merkle.File{Data: string(fileData)})
+// AppendFiles stores files as the next files and adds them to the tree in
+// one epoch, like AppendFile but sealing once for the whole batch
+func (s *Server) AppendFiles(files [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.seal()
+	for _, data := range files {
+		if _, err := s.enqueue(data); err != nil {
+			return err
+		}
 	}
-	s.MerkleTree = merkle.BuildMerkleTree(files)
+	return nil
+}
+
+// fileData returns a sealed file, reading it from the Store when it isn't
+// held in memory; the caller holds s.mu
+func (s *Server) fileData(fileIndex int) ([]byte, error) {
+	if data := s.Files[fileIndex]; data != nil || s.Store == nil {
+		return data, nil
+	}
+	return s.Store.Get(fileIndex)
+}
+
+func (s *Server) appendVersionHash(hash [32]byte) {
+	s.Versions = append(s.Versions, s.latestVersion().AppendHash(hash))
+}
+
+// latestVersion returns the tree of every sealed file; the caller holds s.mu
+func (s *Server) latestVersion() *merkle.PersistentTree {
+	if len(s.Versions) == 0 {
+		return &merkle.PersistentTree{}
+	}
+	return s.Versions[len(s.Versions)-1]
 }
 
 func (s *Server) GetMerkleRootHash() [32]byte {
-	if s.MerkleTree.Root == nil {
-		return [32]byte{}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rootHash()
+}
+
+func (s *Server) rootHash() [32]byte {
+	if s.MountainRange != nil {
+		return s.MountainRange.Root()
 	}
-	return s.MerkleTree.Root.Hash
+	return s.latestVersion().RootHash()
 }
 
 func (s *Server) GenerateMerkleProof(fileIndex int) ([][32]byte, []bool, error) {
-	if fileIndex < 0 || fileIndex >= len(s.MerkleTree.Leaves) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.MountainRange != nil {
+		return s.generateMerkleProofAt(s.MountainRange.Size(), fileIndex)
+	}
+	if fileIndex < 0 || fileIndex >= len(s.Versions) {
 		return nil, nil, fmt.Errorf("file index out of range")
 	}
-	return s.MerkleTree.GenerateProof(fileIndex)
+	return s.latestVersion().GenerateProof(fileIndex)
+}
+
+// GetMerkleRootHashAt returns the root hash of the tree as it was when it held treeSize files
+func (s *Server) GetMerkleRootHashAt(treeSize int) ([32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.MountainRange != nil {
+		if treeSize < 1 {
+			return [32]byte{}, fmt.Errorf("tree size out of range")
+		}
+		return s.MountainRange.RootAt(treeSize)
+	}
+	if treeSize < 1 || treeSize > len(s.Versions) {
+		return [32]byte{}, fmt.Errorf("tree size out of range")
+	}
+	return s.Versions[treeSize-1].RootHash(), nil
+}
+
+// GenerateMerkleProofAt generates a proof for fileIndex against the tree as it was when it held treeSize files
+func (s *Server) GenerateMerkleProofAt(treeSize, fileIndex int) ([][32]byte, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.generateMerkleProofAt(treeSize, fileIndex)
+}
+
+func (s *Server) generateMerkleProofAt(treeSize, fileIndex int) ([][32]byte, []bool, error) {
+	if s.MountainRange != nil {
+		proof, err := s.MountainRange.GenerateProofAt(treeSize, fileIndex)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes, directions := proof.Flatten()
+		return hashes, directions, nil
+	}
+	if treeSize < 1 || treeSize > len(s.Versions) {
+		return nil, nil, fmt.Errorf("tree size out of range")
+	}
+	return s.Versions[treeSize-1].GenerateProof(fileIndex)
+}
+
+// GetNodeHashes returns internal node hashes of the tree as it was when it held treeSize files.
+// Level 0 holds the leaves; see merkle.Tree.NodeHash.
+func (s *Server) GetNodeHashes(treeSize, level int, indices []int) ([][32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.MountainRange != nil {
+		return nil, fmt.Errorf("node hashes are not available in %s mode", TreeModeMMR)
+	}
+	if treeSize < 1 || treeSize > len(s.Versions) {
+		return nil, fmt.Errorf("tree size out of range")
+	}
+	return s.Versions[treeSize-1].NodeHashes(level, indices)
+}
+
+// GetSignedTreeHead returns the signed tree head for the current tree. A new
+// head is only signed when the tree has grown, so that witness cosignatures
+// collected for the latest head keep being served until the next upload.
+func (s *Server) GetSignedTreeHead() (*treehead.SignedTreeHead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signedTreeHead()
+}
+
+func (s *Server) signedTreeHead() (*treehead.SignedTreeHead, error) {
+	if s.SigningKey == nil {
+		return nil, ErrNoSigningKey
+	}
+	if s.treeHead == nil || s.treeHead.TreeSize != len(s.Files) {
+		s.treeHead = treehead.Sign(s.SigningKey, len(s.Files), s.rootHash(), time.Now())
+	}
+
+	sth := *s.treeHead
+	sth.Cosignatures = append([]treehead.Cosignature(nil), s.treeHead.Cosignatures...)
+	return &sth, nil
+}
+
+// AddCosignatures attaches witness cosignatures to the tree head they were
+// made for. Cosignatures for a head that has since been replaced are dropped.
+func (s *Server) AddCosignatures(sth *treehead.SignedTreeHead, cosignatures []treehead.Cosignature) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.treeHead == nil || s.treeHead.Timestamp != sth.Timestamp ||
+		s.treeHead.TreeSize != sth.TreeSize || s.treeHead.RootHash != sth.RootHash {
+		return
+	}
+
+	for _, cosig := range cosignatures {
+		replaced := false
+		for i, existing := range s.treeHead.Cosignatures {
+			if existing.WitnessKey == cosig.WitnessKey {
+				s.treeHead.Cosignatures[i] = cosig
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.treeHead.Cosignatures = append(s.treeHead.Cosignatures, cosig)
+		}
+	}
+}
+
+// GetConsistencyProof proves that the tree of oldSize files is a prefix of the tree of newSize files
+func (s *Server) GetConsistencyProof(oldSize, newSize int) ([][32]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.MountainRange != nil {
+		return nil, fmt.Errorf("consistency proofs are not available in %s mode", TreeModeMMR)
+	}
+	if newSize < 1 || newSize > len(s.Versions) {
+		return nil, fmt.Errorf("tree size out of range")
+	}
+	return merkle.ConsistencyProof(s.Versions[newSize-1], oldSize)
+}
+
+// IssueInclusionPromise signs a promise that the file at fileIndex will be
+// included in a signed tree head within MaxMergeDelay
+func (s *Server) IssueInclusionPromise(fileIndex int) (*treehead.InclusionPromise, error) {
+	if s.SigningKey == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var leafHash [32]byte
+	switch {
+	case fileIndex >= 0 && fileIndex < len(s.Files):
+		hash, err := s.leafHash(fileIndex)
+		if err != nil {
+			return nil, err
+		}
+		leafHash = hash
+	case fileIndex >= len(s.Files) && fileIndex < len(s.Files)+len(s.pending):
+		leafHash = merkle.CreateHash(s.pending[fileIndex-len(s.Files)])
+	default:
+		return nil, fmt.Errorf("file index out of range")
+	}
+	return treehead.Promise(s.SigningKey, leafHash, fileIndex, time.Now(), s.MaxMergeDelay), nil
 }
@@ -2,7 +2,13 @@ package server
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
 )
 
 func TestNewServer(t *testing.T) {
@@ -10,8 +16,8 @@ func TestNewServer(t *testing.T) {
 	if server == nil {
 		t.Fatal("NewServer returned nil")
 	}
-	if server.MerkleTree == nil {
-		t.Error("NewServer: MerkleTree is nil")
+	if server.GetMerkleRootHash() != ([32]byte{}) {
+		t.Error("NewServer: Expected the zero root hash for an empty tree")
 	}
 	if len(server.Files) != 0 {
 		t.Errorf("NewServer: Expected 0 files, got %d", len(server.Files))
@@ -29,8 +35,8 @@ func TestUploadFile(t *testing.T) {
 	if !bytes.Equal(server.Files[0], testData) {
 		t.Error("UploadFile: Stored file data doesn't match uploaded data")
 	}
-	if server.MerkleTree.Root == nil {
-		t.Error("UploadFile: MerkleTree root is nil after upload")
+	if len(server.Versions) != 1 || server.GetMerkleRootHash() != merkle.CreateHash(testData) {
+		t.Error("UploadFile: Merkle root doesn't cover the uploaded file")
 	}
 }
 
@@ -128,3 +134,212 @@ func TestUpdateMerkleTree(t *testing.T) {
 func TestServerInterface(t *testing.T) {
 	var _ ServerInterface = (*Server)(nil)
 }
+
+func TestGenerateMerkleProofAt(t *testing.T) {
+	server := NewServer()
+	server.UploadFile("test1.txt", []byte("test1"))
+	server.UploadFile("test2.txt", []byte("test2"))
+	firstHash, err := server.GetMerkleRootHashAt(1)
+	if err != nil {
+		t.Fatalf("GetMerkleRootHashAt: Unexpected error: %v", err)
+	}
+
+	server.UploadFile("test3.txt", []byte("test3"))
+	latestHash, _ := server.GetMerkleRootHashAt(3)
+	if latestHash != server.GetMerkleRootHash() {
+		t.Error("GetMerkleRootHashAt: Latest version doesn't match current root")
+	}
+	if firstHash == latestHash {
+		t.Error("GetMerkleRootHashAt: Expected older version to have a different root")
+	}
+
+	proof, _, err := server.GenerateMerkleProofAt(2, 1)
+	if err != nil {
+		t.Errorf("GenerateMerkleProofAt: Unexpected error: %v", err)
+	}
+	if len(proof) != 1 {
+		t.Errorf("GenerateMerkleProofAt: Expected proof length 1, got %d", len(proof))
+	}
+
+	_, _, err = server.GenerateMerkleProofAt(4, 0)
+	if err == nil {
+		t.Error("GenerateMerkleProofAt: Expected error for unknown tree size, got nil")
+	}
+}
+
+func TestNewServerWithTreeMode(t *testing.T) {
+	server, err := NewServerWithTreeMode(TreeModeMMR)
+	if err != nil {
+		t.Fatalf("NewServerWithTreeMode: Unexpected error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		server.UploadFile("test.txt", []byte{byte(i)})
+	}
+
+	if server.GetMerkleRootHash() != server.MountainRange.Root() {
+		t.Error("NewServerWithTreeMode: Root hash doesn't come from the mountain range")
+	}
+	proof, directions, err := server.GenerateMerkleProof(4)
+	if err != nil {
+		t.Errorf("GenerateMerkleProof: Unexpected error: %v", err)
+	}
+	if len(proof) != len(directions) {
+		t.Error("GenerateMerkleProof: Mismatch between proof and directions length")
+	}
+	if _, _, err := server.GenerateMerkleProof(5); err == nil {
+		t.Error("GenerateMerkleProof: Expected error for out of range index, got nil")
+	}
+
+	if _, err := NewServerWithTreeMode("unknown"); err == nil {
+		t.Error("NewServerWithTreeMode: Expected error for unknown mode, got nil")
+	}
+}
+
+func TestGetNodeHashes(t *testing.T) {
+	server := NewServer()
+	for i := 0; i < 5; i++ {
+		server.UploadFile("test.txt", []byte{byte(i)})
+	}
+
+	hashes, err := server.GetNodeHashes(5, 3, []int{0})
+	if err != nil {
+		t.Fatalf("GetNodeHashes: Unexpected error: %v", err)
+	}
+	if hashes[0] != server.GetMerkleRootHash() {
+		t.Error("GetNodeHashes: Top level node should be the root")
+	}
+
+	old, _ := server.GetMerkleRootHashAt(2)
+	hashes, err = server.GetNodeHashes(2, 1, []int{0})
+	if err != nil || hashes[0] != old {
+		t.Error("GetNodeHashes: Expected node hashes of an earlier tree size")
+	}
+
+	if _, err := server.GetNodeHashes(5, 0, []int{5}); err == nil {
+		t.Error("GetNodeHashes: Expected error for out of range index, got nil")
+	}
+}
+
+func TestGetSignedTreeHead(t *testing.T) {
+	server := NewServer()
+	if _, err := server.GetSignedTreeHead(); err == nil {
+		t.Error("GetSignedTreeHead: Expected error without a signing key, got nil")
+	}
+
+	public, private, _ := ed25519.GenerateKey(nil)
+	server.SigningKey = private
+	server.UploadFile("test.txt", []byte("test"))
+
+	sth, err := server.GetSignedTreeHead()
+	if err != nil {
+		t.Fatalf("GetSignedTreeHead: Unexpected error: %v", err)
+	}
+	if err := sth.Verify(public); err != nil {
+		t.Errorf("GetSignedTreeHead: Signature doesn't verify: %v", err)
+	}
+	if root, _ := sth.Root(); sth.TreeSize != 1 || root != server.GetMerkleRootHash() {
+		t.Error("GetSignedTreeHead: Tree head doesn't match the current tree")
+	}
+}
+
+func TestGetConsistencyProof(t *testing.T) {
+	server := NewServer()
+	for i := 0; i < 7; i++ {
+		server.UploadFile("test.txt", []byte{byte(i)})
+	}
+
+	oldRoot, _ := server.GetMerkleRootHashAt(3)
+	proof, err := server.GetConsistencyProof(3, 7)
+	if err != nil {
+		t.Fatalf("GetConsistencyProof: Unexpected error: %v", err)
+	}
+	if !merkle.VerifyConsistency(3, 7, oldRoot, server.GetMerkleRootHash(), proof) {
+		t.Error("GetConsistencyProof: Proof doesn't verify")
+	}
+
+	if _, err := server.GetConsistencyProof(3, 8); err == nil {
+		t.Error("GetConsistencyProof: Expected error for unknown tree size, got nil")
+	}
+}
+
+func TestReadOnlyServer(t *testing.T) {
+	server := NewServer()
+	server.ReadOnly = true
+
+	if _, err := server.UploadFile("test.txt", []byte("test")); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("UploadFile: Expected ErrReadOnly, got %v", err)
+	}
+	if _, err := server.AppendFile([]byte("test")); err != nil {
+		t.Errorf("AppendFile: Unexpected error: %v", err)
+	}
+}
+
+func TestAppendFiles(t *testing.T) {
+	server := NewServer()
+	server.ReadOnly = true
+	if err := server.AppendFiles([][]byte{[]byte("a"), []byte("b"), []byte("c")}); err != nil {
+		t.Fatalf("AppendFiles: Unexpected error: %v", err)
+	}
+
+	want := NewServer()
+	for _, data := range []string{"a", "b", "c"} {
+		want.AppendFile([]byte(data))
+	}
+	if server.GetMerkleRootHash() != want.GetMerkleRootHash() {
+		t.Error("AppendFiles: Root hash doesn't match appending the files one by one")
+	}
+	if epoch, _ := server.EpochOf(2); epoch != 1 {
+		t.Errorf("AppendFiles: Expected the files to be sealed in one epoch, got epoch %d", epoch)
+	}
+}
+
+func TestAddCosignatures(t *testing.T) {
+	server := NewServer()
+	_, server.SigningKey, _ = ed25519.GenerateKey(nil)
+	_, witnessKey, _ := ed25519.GenerateKey(nil)
+	server.UploadFile("test.txt", []byte("test"))
+
+	sth, _ := server.GetSignedTreeHead()
+	cosig, _ := treehead.Cosign(witnessKey, sth, time.Now())
+	server.AddCosignatures(sth, []treehead.Cosignature{cosig})
+	server.AddCosignatures(sth, []treehead.Cosignature{cosig})
+
+	cosigned, _ := server.GetSignedTreeHead()
+	if cosigned.Timestamp != sth.Timestamp || len(cosigned.Cosignatures) != 1 {
+		t.Fatalf("GetSignedTreeHead: Expected the same tree head with one cosignature, got %+v", cosigned)
+	}
+
+	server.UploadFile("test2.txt", []byte("test2"))
+	server.AddCosignatures(sth, []treehead.Cosignature{cosig})
+	if latest, _ := server.GetSignedTreeHead(); latest.TreeSize != 2 || len(latest.Cosignatures) != 0 {
+		t.Error("AddCosignatures: Cosignatures for a replaced tree head should be dropped")
+	}
+}
+
+func TestIssueInclusionPromise(t *testing.T) {
+	server := NewServer()
+	server.UploadFile("test.txt", []byte("test"))
+	if _, err := server.IssueInclusionPromise(0); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("IssueInclusionPromise: Expected ErrNoSigningKey, got %v", err)
+	}
+
+	public, private, _ := ed25519.GenerateKey(nil)
+	server.SigningKey = private
+	promise, err := server.IssueInclusionPromise(0)
+	if err != nil {
+		t.Fatalf("IssueInclusionPromise: Unexpected error: %v", err)
+	}
+	if err := promise.Verify(public); err != nil {
+		t.Errorf("IssueInclusionPromise: Promise doesn't verify: %v", err)
+	}
+	if leaf, _ := promise.Leaf(); leaf != merkle.CreateHash([]byte("test")) || promise.Index != 0 {
+		t.Error("IssueInclusionPromise: Promise doesn't match the uploaded file")
+	}
+	if promise.MaxMergeDelay != DefaultMaxMergeDelay.Milliseconds() {
+		t.Errorf("IssueInclusionPromise: Expected the default merge delay, got %dms", promise.MaxMergeDelay)
+	}
+
+	if _, err := server.IssueInclusionPromise(1); err == nil {
+		t.Error("IssueInclusionPromise: Expected error for out of range index, got nil")
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// pendingTimestamp is a hash waiting for the current epoch to be sealed
+type pendingTimestamp struct {
+	hash [32]byte
+	done chan timestampResult
+}
+
+type timestampResult struct {
+	receipt *treehead.TimestampReceipt
+	err     error
+}
+
+// Timestamp queues a document hash for the current epoch and waits until
+// Seal closes the epoch, returning a receipt proving the hash is included in
+// a signed tree head. The hash is appended to the tree as a 32-byte file.
+func (s *Server) Timestamp(ctx context.Context, hash [32]byte) (*treehead.TimestampReceipt, error) {
+	if s.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	if s.SigningKey == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	pending := pendingTimestamp{hash: hash, done: make(chan timestampResult, 1)}
+	s.timestampMu.Lock()
+	s.timestamps = append(s.timestamps, pending)
+	s.timestampMu.Unlock()
+
+	select {
+	case result := <-pending.done:
+		return result.receipt, result.err
+	case <-ctx.Done():
+		// The hash is still included when the epoch is sealed
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+func TestTimestampEpoch(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	server := NewServer()
+	server.SigningKey = private
+	server.UploadFile("test.txt", []byte("test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.RunEpochs(ctx, 10*time.Millisecond)
+
+	var wg sync.WaitGroup
+	receipts := make([]*treehead.TimestampReceipt, 5)
+	for i := range receipts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			receipt, err := server.Timestamp(ctx, merkle.CreateHash([]byte{byte(i)}))
+			if err != nil {
+				t.Errorf("Timestamp: Unexpected error: %v", err)
+				return
+			}
+			receipts[i] = receipt
+		}(i)
+	}
+	wg.Wait()
+
+	for i, receipt := range receipts {
+		if receipt == nil {
+			continue
+		}
+		if _, err := receipt.Verify(public); err != nil {
+			t.Errorf("Timestamp: Receipt %d doesn't verify: %v", i, err)
+		}
+		if hash, _ := receipt.DocumentHash(); hash != merkle.CreateHash([]byte{byte(i)}) {
+			t.Errorf("Timestamp: Receipt %d is for the wrong hash", i)
+		}
+	}
+	if server.GetFileCount() != 6 {
+		t.Errorf("Timestamp: Expected 6 leaves, got %d", server.GetFileCount())
+	}
+}
+
+func TestTimestampRejected(t *testing.T) {
+	server := NewServer()
+	if _, err := server.Timestamp(context.Background(), [32]byte{}); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Timestamp: Expected ErrNoSigningKey, got %v", err)
+	}
+
+	_, server.SigningKey, _ = ed25519.GenerateKey(nil)
+	server.ReadOnly = true
+	if _, err := server.Timestamp(context.Background(), [32]byte{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Timestamp: Expected ErrReadOnly, got %v", err)
+	}
+
+	server.ReadOnly = false
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := server.Timestamp(ctx, [32]byte{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Timestamp: Expected the context error without a sealed epoch, got %v", err)
+	}
+}
+
+func TestSealChecksTimestampAdmission(t *testing.T) {
+	server := NewServer()
+	_, server.SigningKey, _ = ed25519.GenerateKey(nil)
+	server.UploadFile("test.txt", []byte("test"))
+
+	// Hashes queued by Timestamp are checked again when the epoch is sealed
+	queue := func(hash byte) chan timestampResult {
+		done := make(chan timestampResult, 1)
+		server.timestamps = append(server.timestamps, pendingTimestamp{hash: [32]byte{hash}, done: done})
+		return done
+	}
+
+	server.QuotaBytes = server.UsedBytes() + 16
+	done := queue(1)
+	server.Seal()
+	if result := <-done; !errors.Is(result.err, ErrQuotaExceeded) {
+		t.Errorf("Seal: Expected ErrQuotaExceeded for a timestamp over the quota, got %v", result.err)
+	}
+
+	server.QuotaBytes = 0
+	server.ReadOnly = true
+	done = queue(2)
+	server.Seal()
+	if result := <-done; !errors.Is(result.err, ErrReadOnly) {
+		t.Errorf("Seal: Expected ErrReadOnly for a timestamp queued before the server became read-only, got %v", result.err)
+	}
+	if server.GetFileCount() != 1 {
+		t.Errorf("Seal: Expected refused timestamps not to be added, got %d files", server.GetFileCount())
+	}
+}
@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// compressedMagic starts every blob written by a CompressedStore, followed
+// by one of the encodings below. Blobs without it were stored before
+// compression was enabled and are returned as they are.
+var compressedMagic = []byte("GMZ1")
+
+const (
+	encodingIdentity = 0
+	encodingGzip     = 1
+)
+
+// GzipReader is implemented by stores that can return a blob still
+// gzip-compressed, so it can be served without recompressing it
+type GzipReader interface {
+	// GetGzip returns the gzip stream of the blob at index, or false if the
+	// blob is not stored compressed
+	GetGzip(index int) ([]byte, bool, error)
+}
+
+// CompressionStats counts the bytes held in a CompressedStore
+type CompressionStats struct {
+	Blobs             int   `json:"blobs"`
+	BytesUncompressed int64 `json:"bytesUncompressed"`
+	BytesStored       int64 `json:"bytesStored"`
+}
+
+// BytesSaved is how many bytes compression saved
+func (s CompressionStats) BytesSaved() int64 {
+	return s.BytesUncompressed - s.BytesStored
+}
+
+// sizeRecordSize is the length of a record in the sizes file: the blob
+// index, its uncompressed size and its stored size as big-endian uint64s
+const sizeRecordSize = 3 * 8
+
+// CompressedStore gzips blobs before writing them to another Store. A blob
+// that doesn't get smaller is stored uncompressed. Callers always see the
+// uncompressed bytes, so leaf hashes are unaffected.
+type CompressedStore struct {
+	inner     Store
+	sizesPath string
+
+	mu sync.Mutex
+	// sizes holds the uncompressed and stored size of every blob
+	sizes map[int][2]int64
+}
+
+// NewCompressedStore compresses the blobs of inner. The size of every blob
+// is recorded in the file at sizesPath so that Stats covers the whole store
+// across restarts; blobs missing from it, such as ones stored before
+// compression was enabled, are read once to fill it in. An empty sizesPath
+// keeps the sizes in memory, so only blobs written since then are counted.
+func NewCompressedStore(inner Store, sizesPath string) (*CompressedStore, error) {
+	s := &CompressedStore{inner: inner, sizesPath: sizesPath, sizes: map[int][2]int64{}}
+	if sizesPath == "" {
+		return s, nil
+	}
+
+	records, err := os.ReadFile(sizesPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading blob sizes: %w", err)
+	}
+	// A record cut short by a crash is dropped and recomputed below
+	for len(records) >= sizeRecordSize {
+		index := int(binary.BigEndian.Uint64(records))
+		uncompressed := int64(binary.BigEndian.Uint64(records[8:]))
+		stored := int64(binary.BigEndian.Uint64(records[16:]))
+		s.sizes[index] = [2]int64{uncompressed, stored}
+		records = records[sizeRecordSize:]
+	}
+
+	for index := 0; index < inner.Len(); index++ {
+		if _, ok := s.sizes[index]; ok {
+			continue
+		}
+		blob, err := inner.Get(index)
+		if err != nil {
+			return nil, fmt.Errorf("sizing blob %d: %w", index, err)
+		}
+		uncompressed, err := uncompressedSize(blob)
+		if err != nil {
+			return nil, fmt.Errorf("sizing blob %d: %w", index, err)
+		}
+		if err := s.record(index, uncompressed, int64(len(blob))); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Put compresses and writes the blob
+func (s *CompressedStore) Put(index int, data []byte) error {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	blob := append([]byte{}, compressedMagic...)
+	if compressed.Len() < len(data) {
+		blob = append(append(blob, encodingGzip), compressed.Bytes()...)
+	} else {
+		blob = append(append(blob, encodingIdentity), data...)
+	}
+	if err := s.inner.Put(index, blob); err != nil {
+		return err
+	}
+	// The blob is stored either way, and a size that couldn't be recorded is
+	// recomputed when the store is next opened
+	s.record(index, int64(len(data)), int64(len(blob)))
+	return nil
+}
+
+// Get reads and decompresses the blob stored at index
+func (s *CompressedStore) Get(index int) ([]byte, error) {
+	blob, err := s.inner.Get(index)
+	if err != nil {
+		return nil, err
+	}
+
+	data := blob
+	encoding, payload, ok := splitCompressed(blob)
+	if ok && encoding == encodingGzip {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing blob %d: %w", index, err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing blob %d: %w", index, err)
+		}
+	} else if ok {
+		data = payload
+	}
+	return data, nil
+}
+
+// GetGzip returns the stored gzip stream of the blob at index without decompressing it
+func (s *CompressedStore) GetGzip(index int) ([]byte, bool, error) {
+	blob, err := s.inner.Get(index)
+	if err != nil {
+		return nil, false, err
+	}
+	encoding, payload, ok := splitCompressed(blob)
+	if !ok || encoding != encodingGzip {
+		return nil, false, nil
+	}
+	return payload, true, nil
+}
+
+// Len returns the number of stored blobs
+func (s *CompressedStore) Len() int {
+	return s.inner.Len()
+}
+
+// Stats returns the sizes of the blobs in the store
+func (s *CompressedStore) Stats() CompressionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := CompressionStats{Blobs: len(s.sizes)}
+	for _, size := range s.sizes {
+		stats.BytesUncompressed += size[0]
+		stats.BytesStored += size[1]
+	}
+	return stats
+}
+
+// record keeps the sizes of a blob and appends them to the sizes file
+func (s *CompressedStore) record(index int, uncompressed, stored int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes[index] = [2]int64{uncompressed, stored}
+	if s.sizesPath == "" {
+		return nil
+	}
+
+	record := make([]byte, 0, sizeRecordSize)
+	record = binary.BigEndian.AppendUint64(record, uint64(index))
+	record = binary.BigEndian.AppendUint64(record, uint64(uncompressed))
+	record = binary.BigEndian.AppendUint64(record, uint64(stored))
+	f, err := os.OpenFile(s.sizesPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("recording blob size: %w", err)
+	}
+	if _, err := f.Write(record); err != nil {
+		f.Close()
+		return fmt.Errorf("recording blob size: %w", err)
+	}
+	return f.Close()
+}
+
+// uncompressedSize returns the size of the data a stored blob holds
+func uncompressedSize(blob []byte) (int64, error) {
+	encoding, payload, ok := splitCompressed(blob)
+	if !ok {
+		return int64(len(blob)), nil
+	}
+	if encoding == encodingIdentity {
+		return int64(len(payload)), nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(io.Discard, zr)
+}
+
+// splitCompressed returns the encoding and payload of a blob written by a
+// CompressedStore, or false for a blob stored before compression was enabled
+func splitCompressed(blob []byte) (byte, []byte, bool) {
+	if len(blob) <= len(compressedMagic) || !bytes.HasPrefix(blob, compressedMagic) {
+		return 0, nil, false
+	}
+	encoding := blob[len(compressedMagic)]
+	if encoding != encodingIdentity && encoding != encodingGzip {
+		return 0, nil, false
+	}
+	return encoding, blob[len(compressedMagic)+1:], true
+}
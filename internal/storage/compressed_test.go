@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressedStore(t *testing.T) {
+	dir := t.TempDir()
+	inner, _ := NewDiskStore(dir)
+
+	// A blob stored before compression was enabled is still readable
+	legacy := []byte("written before compression")
+	if err := inner.Put(0, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := filepath.Join(t.TempDir(), "compressed.sizes")
+	store, err := NewCompressedStore(inner, sizes)
+	if err != nil {
+		t.Fatalf("NewCompressedStore: Unexpected error: %v", err)
+	}
+	text := []byte(strings.Repeat("INFO request served in 3ms\n", 500))
+	random := make([]byte, 1000)
+	rand.Read(random)
+	if err := store.Put(1, text); err != nil {
+		t.Fatalf("Put: Unexpected error: %v", err)
+	}
+	if err := store.Put(2, random); err != nil {
+		t.Fatalf("Put: Unexpected error: %v", err)
+	}
+
+	raw, _ := os.ReadFile(filepath.Join(dir, "00000001.blob"))
+	if len(raw) >= len(text)/10 {
+		t.Errorf("Put: Expected text to compress well, stored %d of %d bytes", len(raw), len(text))
+	}
+	for i, want := range [][]byte{legacy, text, random} {
+		data, err := store.Get(i)
+		if err != nil || !bytes.Equal(data, want) {
+			t.Errorf("Get(%d): Expected the original bytes, got %d bytes (%v)", i, len(data), err)
+		}
+	}
+
+	compressed, ok, err := store.GetGzip(1)
+	if err != nil || !ok {
+		t.Fatalf("GetGzip: Expected a gzip stream, got %v (%v)", ok, err)
+	}
+	zr, _ := gzip.NewReader(bytes.NewReader(compressed))
+	if data, _ := io.ReadAll(zr); !bytes.Equal(data, text) {
+		t.Error("GetGzip: Stream does not decompress to the original bytes")
+	}
+	// Incompressible and legacy blobs are not stored as gzip
+	for _, index := range []int{0, 2} {
+		if _, ok, _ := store.GetGzip(index); ok {
+			t.Errorf("GetGzip(%d): Expected no gzip stream", index)
+		}
+	}
+
+	stats := store.Stats()
+	if stats.Blobs != 3 || stats.BytesUncompressed != int64(len(legacy)+len(text)+len(random)) || stats.BytesSaved() <= int64(len(text))/2 {
+		t.Errorf("Stats: Unexpected stats %+v", stats)
+	}
+
+	// The sizes are reloaded on restart without reading the blobs
+	os.Remove(filepath.Join(dir, "00000001.blob"))
+	reopened, err := NewCompressedStore(inner, sizes)
+	if err != nil {
+		t.Fatalf("NewCompressedStore: Unexpected error on reopen: %v", err)
+	}
+	if reopened.Stats() != stats {
+		t.Errorf("Stats: Expected %+v after reopening, got %+v", stats, reopened.Stats())
+	}
+}
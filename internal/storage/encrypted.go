@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EncryptedStore encrypts blobs at rest in another Store. Every blob has its
+// own random data key; the data key is wrapped with the keyring's primary key
+// and kept in a small file of its own, so rotating the key encryption key
+// rewrites only those files and never the blobs. Callers see plaintext, so
+// leaf hashes don't depend on the keys.
+type EncryptedStore struct {
+	inner   Store
+	keyring *Keyring
+	keyDir  string
+}
+
+// wrappedKey is a blob's data key sealed with a key from the keyring
+type wrappedKey struct {
+	KeyID string `json:"keyId"`
+	// Key is the hex-encoded output of seal: nonce then ciphertext
+	Key string `json:"wrappedKey"`
+}
+
+// NewEncryptedStore encrypts the blobs of inner, keeping wrapped data keys in keyDir
+func NewEncryptedStore(inner Store, keyring *Keyring, keyDir string) (*EncryptedStore, error) {
+	if err := os.MkdirAll(keyDir, 0700); err != nil {
+		return nil, fmt.Errorf("creating key directory: %w", err)
+	}
+	return &EncryptedStore{inner: inner, keyring: keyring, keyDir: keyDir}, nil
+}
+
+func (s *EncryptedStore) keyPath(index int) string {
+	return filepath.Join(s.keyDir, fmt.Sprintf("%08d.key", index))
+}
+
+// Put encrypts the blob under a new data key. The wrapped key is written
+// first, so every stored blob has one.
+func (s *EncryptedStore) Put(index int, data []byte) error {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	if err := s.wrap(index, dataKey); err != nil {
+		return err
+	}
+
+	ciphertext, err := seal(dataKey, data, blobAAD("blob", index, ""))
+	if err != nil {
+		return err
+	}
+	return s.inner.Put(index, ciphertext)
+}
+
+// Get decrypts the blob stored at index
+func (s *EncryptedStore) Get(index int) ([]byte, error) {
+	ciphertext, err := s.inner.Get(index)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, _, err := s.unwrap(index)
+	if err != nil {
+		return nil, err
+	}
+	data, err := open(dataKey, ciphertext, blobAAD("blob", index, ""))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting blob %d: %w", index, err)
+	}
+	return data, nil
+}
+
+// Len returns the number of stored blobs
+func (s *EncryptedStore) Len() int {
+	return s.inner.Len()
+}
+
+// Rewrap wraps every data key that is not yet wrapped with the keyring's
+// primary key with it, and returns how many it rewrote. Blobs are untouched.
+func (s *EncryptedStore) Rewrap() (int, error) {
+	primary, _ := s.keyring.Primary()
+	rewrapped := 0
+	for index := 0; index < s.Len(); index++ {
+		dataKey, keyID, err := s.unwrap(index)
+		if err != nil {
+			return rewrapped, err
+		}
+		if keyID == primary {
+			continue
+		}
+		if err := s.wrap(index, dataKey); err != nil {
+			return rewrapped, err
+		}
+		rewrapped++
+	}
+	return rewrapped, nil
+}
+
+// wrap encrypts a data key with the primary key and saves it for the blob at index
+func (s *EncryptedStore) wrap(index int, dataKey []byte) error {
+	keyID, kek := s.keyring.Primary()
+	sealed, err := seal(kek, dataKey, blobAAD("key", index, keyID))
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(wrappedKey{KeyID: keyID, Key: hex.EncodeToString(sealed)})
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(s.keyPath(index), encoded); err != nil {
+		return fmt.Errorf("writing data key %d: %w", index, err)
+	}
+	return nil
+}
+
+// unwrap loads and decrypts the data key of the blob at index, returning it
+// with the ID of the key that wrapped it
+func (s *EncryptedStore) unwrap(index int) ([]byte, string, error) {
+	encoded, err := os.ReadFile(s.keyPath(index))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading data key %d: %w", index, err)
+	}
+	var wrapped wrappedKey
+	if err := json.Unmarshal(encoded, &wrapped); err != nil {
+		return nil, "", fmt.Errorf("decoding data key %d: %w", index, err)
+	}
+	kek, err := s.keyring.Key(wrapped.KeyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("data key %d: %w", index, err)
+	}
+
+	sealed, err := hex.DecodeString(wrapped.Key)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding data key %d: %w", index, err)
+	}
+	dataKey, err := open(kek, sealed, blobAAD("key", index, wrapped.KeyID))
+	if err != nil {
+		return nil, "", fmt.Errorf("unwrapping data key %d: %w", index, err)
+	}
+	return dataKey, wrapped.KeyID, nil
+}
+
+// blobAAD binds a ciphertext to its purpose, blob index and wrapping key,
+// so ciphertexts can't be swapped between blobs
+func blobAAD(purpose string, index int, keyID string) []byte {
+	aad := binary.BigEndian.AppendUint64([]byte(purpose), uint64(index))
+	return append(aad, keyID...)
+}
+
+// seal encrypts plaintext with AES-256-GCM under key and prepends the random nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open decrypts a ciphertext made by seal
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is truncated")
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	root := t.TempDir()
+	keyring, err := LoadOrCreateKeyring(filepath.Join(root, "keyring.json"))
+	if err != nil {
+		t.Fatalf("LoadOrCreateKeyring: Unexpected error: %v", err)
+	}
+	inner, _ := NewDiskStore(filepath.Join(root, "files"))
+	store, err := NewEncryptedStore(inner, keyring, filepath.Join(root, "keys"))
+	if err != nil {
+		t.Fatalf("NewEncryptedStore: Unexpected error: %v", err)
+	}
+
+	blobs := [][]byte{[]byte("patient record: alice"), []byte("patient record: bob")}
+	for i, blob := range blobs {
+		if err := store.Put(i, blob); err != nil {
+			t.Fatalf("Put: Unexpected error: %v", err)
+		}
+	}
+
+	// Nothing on disk may contain the plaintext, neither blobs nor keys
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		raw, _ := os.ReadFile(path)
+		if bytes.Contains(raw, []byte("patient record")) {
+			t.Errorf("%s contains plaintext", path)
+		}
+		return nil
+	})
+
+	// Rotation re-wraps the data keys without touching the blobs
+	blobPath := inner.path(0)
+	before, _ := os.ReadFile(blobPath)
+	if _, err := keyring.Rotate(); err != nil {
+		t.Fatalf("Rotate: Unexpected error: %v", err)
+	}
+	if rewrapped, err := store.Rewrap(); err != nil || rewrapped != 2 {
+		t.Fatalf("Rewrap: Expected 2 rewrapped keys, got %d (%v)", rewrapped, err)
+	}
+	if err := keyring.Retire(); err != nil {
+		t.Fatalf("Retire: Unexpected error: %v", err)
+	}
+	after, _ := os.ReadFile(blobPath)
+	if !bytes.Equal(before, after) {
+		t.Error("Rewrap: Expected the blob to be unchanged")
+	}
+
+	reloaded, err := LoadOrCreateKeyring(filepath.Join(root, "keyring.json"))
+	if err != nil {
+		t.Fatalf("LoadOrCreateKeyring: Unexpected error on reload: %v", err)
+	}
+	if id, _ := reloaded.Primary(); id != "2" || len(reloaded.keys) != 1 {
+		t.Errorf("Retire: Expected only key 2 left, got primary %q and %d keys", id, len(reloaded.keys))
+	}
+	store, _ = NewEncryptedStore(inner, reloaded, filepath.Join(root, "keys"))
+	for i, blob := range blobs {
+		data, err := store.Get(i)
+		if err != nil || !bytes.Equal(data, blob) {
+			t.Errorf("Get(%d): Expected plaintext after rotation, got %q (%v)", i, data, err)
+		}
+	}
+
+	// A blob's ciphertext can't be passed off as another blob's
+	if err := os.WriteFile(inner.path(1), after, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(1); err == nil {
+		t.Error("Get: Expected error for a blob swapped with another")
+	}
+}
+
+func TestLockKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	unlock, err := LockKeyring(path)
+	if err != nil {
+		t.Fatalf("LockKeyring: Unexpected error: %v", err)
+	}
+	if _, err := LockKeyring(path); !errors.Is(err, ErrKeyringLocked) {
+		t.Errorf("LockKeyring: Expected ErrKeyringLocked while the lock is held, got %v", err)
+	}
+	unlock()
+	unlock, err = LockKeyring(path)
+	if err != nil {
+		t.Fatalf("LockKeyring: Unexpected error after unlocking: %v", err)
+	}
+	unlock()
+}
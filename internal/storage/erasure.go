@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// manifestSuffix is the extension of the per-blob manifests of an ErasureStore
+const manifestSuffix = ".manifest"
+
+// ErasureStore splits every blob into data and parity shards with
+// Reed-Solomon coding and spreads them across several directories, ideally
+// on separate disks. A blob survives the loss or corruption of up to parity
+// of its shards.
+type ErasureStore struct {
+	dirs         []string
+	dataShards   int
+	parityShards int
+	count        int
+}
+
+// shardManifest describes how a blob was split. It is written to every
+// directory. Shard hashes detect corrupt shards; the blob's leaf hash, the
+// hash the Merkle tree holds for it, checks the reassembled blob and with it
+// the shard hashes, since encoding is deterministic. Under an EncryptedStore
+// the blob is ciphertext, so this is the hash of the ciphertext instead.
+type shardManifest struct {
+	Size         int      `json:"size"`
+	DataShards   int      `json:"dataShards"`
+	ParityShards int      `json:"parityShards"`
+	LeafHash     string   `json:"leafHash"`
+	ShardHashes  []string `json:"shardHashes"`
+}
+
+// NewErasureStore opens or creates a store spread across dirs that codes new
+// blobs into dataShards data and parityShards parity shards. Blobs already
+// stored keep the coding they were written with.
+func NewErasureStore(dirs []string, dataShards, parityShards int) (*ErasureStore, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("erasure coding needs at least one directory")
+	}
+	if _, err := newReedSolomon(dataShards, parityShards); err != nil {
+		return nil, err
+	}
+
+	seen := map[int]bool{}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("creating store directory: %w", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("reading store directory: %w", err)
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, manifestSuffix) {
+				continue
+			}
+			if index, err := strconv.Atoi(strings.TrimSuffix(name, manifestSuffix)); err == nil {
+				seen[index] = true
+			}
+		}
+	}
+
+	indices := make([]int, 0, len(seen))
+	for index := range seen {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	for i, index := range indices {
+		if index != i {
+			return nil, fmt.Errorf("store is missing blob %d", i)
+		}
+	}
+	return &ErasureStore{dirs: dirs, dataShards: dataShards, parityShards: parityShards, count: len(indices)}, nil
+}
+
+func (s *ErasureStore) manifestPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d%s", index, manifestSuffix))
+}
+
+// shardPath places shard i in directory i modulo the number of directories
+func (s *ErasureStore) shardPath(index, shard int) string {
+	return filepath.Join(s.dirs[shard%len(s.dirs)], fmt.Sprintf("%08d.%d.shard", index, shard))
+}
+
+// Put codes and writes the blob. The shards are written before the
+// manifests, so a blob with a manifest is complete. Blobs are append-only,
+// so index must be the next free index.
+func (s *ErasureStore) Put(index int, data []byte) error {
+	if index != s.count {
+		return fmt.Errorf("expected blob %d, got %d", s.count, index)
+	}
+
+	rs, _ := newReedSolomon(s.dataShards, s.parityShards)
+	shards := rs.encode(data)
+	leaf := merkle.CreateHash(data)
+	manifest := shardManifest{
+		Size:         len(data),
+		DataShards:   s.dataShards,
+		ParityShards: s.parityShards,
+		LeafHash:     hex.EncodeToString(leaf[:]),
+	}
+	for i, shard := range shards {
+		hash := merkle.CreateHash(shard)
+		manifest.ShardHashes = append(manifest.ShardHashes, hex.EncodeToString(hash[:]))
+		if err := WriteFileAtomic(s.shardPath(index, i), shard); err != nil {
+			return fmt.Errorf("writing shard %d: %w", i, err)
+		}
+	}
+
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	for _, dir := range s.dirs {
+		if err := WriteFileAtomic(s.manifestPath(dir, index), encoded); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+	}
+	s.count++
+	return nil
+}
+
+// Get reads the blob stored at index, reconstructing it when shards are
+// missing or corrupt
+func (s *ErasureStore) Get(index int) ([]byte, error) {
+	if index < 0 || index >= s.count {
+		return nil, ErrNotFound
+	}
+	blob, err := s.read(index)
+	if err != nil {
+		return nil, err
+	}
+	return blob.data, nil
+}
+
+// Len returns the number of stored blobs
+func (s *ErasureStore) Len() int {
+	return s.count
+}
+
+// Scrub checks every shard and manifest of the blob at index and rewrites
+// the ones that are missing or corrupt. It returns how many files it rewrote.
+func (s *ErasureStore) Scrub(index int) (int, error) {
+	if index < 0 || index >= s.count {
+		return 0, ErrNotFound
+	}
+	blob, err := s.read(index)
+	if err != nil {
+		return 0, err
+	}
+
+	repaired := 0
+	for _, shard := range blob.badShards {
+		if err := WriteFileAtomic(s.shardPath(index, shard), blob.shards[shard]); err != nil {
+			return repaired, fmt.Errorf("repairing shard %d: %w", shard, err)
+		}
+		repaired++
+	}
+	for _, dir := range s.dirs {
+		path := s.manifestPath(dir, index)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, blob.manifest) {
+			continue
+		}
+		if err := WriteFileAtomic(path, blob.manifest); err != nil {
+			return repaired, fmt.Errorf("repairing manifest: %w", err)
+		}
+		repaired++
+	}
+	return repaired, nil
+}
+
+// decodedBlob is a blob read back from its shards
+type decodedBlob struct {
+	data      []byte
+	shards    [][]byte
+	badShards []int
+	manifest  []byte
+}
+
+// read decodes the blob at index with each distinct manifest found until
+// one yields a blob matching its leaf hash
+func (s *ErasureStore) read(index int) (*decodedBlob, error) {
+	var candidates [][]byte
+	for _, dir := range s.dirs {
+		encoded, err := os.ReadFile(s.manifestPath(dir, index))
+		if err != nil {
+			continue
+		}
+		duplicate := false
+		for _, candidate := range candidates {
+			duplicate = duplicate || bytes.Equal(candidate, encoded)
+		}
+		if !duplicate {
+			candidates = append(candidates, encoded)
+		}
+	}
+
+	err := fmt.Errorf("no manifest for blob %d", index)
+	for _, encoded := range candidates {
+		var manifest shardManifest
+		if jsonErr := json.Unmarshal(encoded, &manifest); jsonErr != nil {
+			err = fmt.Errorf("decoding manifest of blob %d: %w", index, jsonErr)
+			continue
+		}
+		var blob *decodedBlob
+		blob, err = s.decode(index, &manifest)
+		if err == nil {
+			blob.manifest = encoded
+			return blob, nil
+		}
+	}
+	return nil, err
+}
+
+// decode reads the shards of the blob at index, drops those that don't
+// match the manifest and rebuilds them from the rest
+func (s *ErasureStore) decode(index int, manifest *shardManifest) (*decodedBlob, error) {
+	rs, err := newReedSolomon(manifest.DataShards, manifest.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.ShardHashes) != manifest.DataShards+manifest.ParityShards {
+		return nil, fmt.Errorf("manifest of blob %d lists %d shard hashes", index, len(manifest.ShardHashes))
+	}
+
+	blob := &decodedBlob{shards: make([][]byte, len(manifest.ShardHashes))}
+	for i, want := range manifest.ShardHashes {
+		shard, err := os.ReadFile(s.shardPath(index, i))
+		hash := merkle.CreateHash(shard)
+		if err != nil || hex.EncodeToString(hash[:]) != want {
+			blob.badShards = append(blob.badShards, i)
+			continue
+		}
+		blob.shards[i] = shard
+	}
+	if err := rs.reconstruct(blob.shards); err != nil {
+		return nil, fmt.Errorf("blob %d: %w", index, err)
+	}
+
+	data := bytes.Join(blob.shards[:manifest.DataShards], nil)
+	if manifest.Size > len(data) {
+		return nil, fmt.Errorf("manifest of blob %d has size %d beyond its shards", index, manifest.Size)
+	}
+	blob.data = data[:manifest.Size]
+	leaf := merkle.CreateHash(blob.data)
+	if hex.EncodeToString(leaf[:]) != manifest.LeafHash {
+		return nil, fmt.Errorf("blob %d does not match its leaf hash", index)
+	}
+	return blob, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReedSolomonReconstruct(t *testing.T) {
+	rs, err := newReedSolomon(3, 2)
+	if err != nil {
+		t.Fatalf("newReedSolomon: Unexpected error: %v", err)
+	}
+	blob := []byte("erasure coded blob of some length")
+	want := rs.encode(blob)
+
+	// Every combination of two lost shards must be recoverable
+	for a := range want {
+		for b := a + 1; b < len(want); b++ {
+			shards := make([][]byte, len(want))
+			copy(shards, want)
+			shards[a], shards[b] = nil, nil
+			if err := rs.reconstruct(shards); err != nil {
+				t.Fatalf("reconstruct without %d and %d: Unexpected error: %v", a, b, err)
+			}
+			for i := range shards {
+				if !bytes.Equal(shards[i], want[i]) {
+					t.Errorf("reconstruct without %d and %d: shard %d differs", a, b, i)
+				}
+			}
+		}
+	}
+
+	shards := [][]byte{want[0], nil, nil, nil, want[4]}
+	if err := rs.reconstruct(shards); !errors.Is(err, ErrTooFewShards) {
+		t.Errorf("reconstruct: Expected ErrTooFewShards, got %v", err)
+	}
+}
+
+func TestErasureStore(t *testing.T) {
+	root := t.TempDir()
+	dirs := []string{filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "c")}
+	store, err := NewErasureStore(dirs, 4, 2)
+	if err != nil {
+		t.Fatalf("NewErasureStore: Unexpected error: %v", err)
+	}
+
+	blobs := [][]byte{[]byte("first blob"), {}, bytes.Repeat([]byte("0123456789"), 1000)}
+	for i, blob := range blobs {
+		if err := store.Put(i, blob); err != nil {
+			t.Fatalf("Put: Unexpected error: %v", err)
+		}
+	}
+	if err := store.Put(7, []byte("gap")); err == nil {
+		t.Error("Put: Expected error for non-sequential index, got nil")
+	}
+
+	// Lose a whole directory (shards 0 and 3 of every blob) and corrupt nothing else
+	if err := os.RemoveAll(dirs[0]); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewErasureStore(dirs, 4, 2)
+	if err != nil {
+		t.Fatalf("NewErasureStore: Unexpected error on reopen: %v", err)
+	}
+	if reopened.Len() != len(blobs) {
+		t.Fatalf("Len: Expected %d blobs after reopen, got %d", len(blobs), reopened.Len())
+	}
+	for i, blob := range blobs {
+		data, err := reopened.Get(i)
+		if err != nil || !bytes.Equal(data, blob) {
+			t.Errorf("Get(%d): Expected reconstructed blob, got %d bytes (%v)", i, len(data), err)
+		}
+	}
+
+	// Scrub rewrites the two lost shards and the lost manifest
+	repaired, err := reopened.Scrub(2)
+	if err != nil || repaired != 3 {
+		t.Errorf("Scrub: Expected 3 repaired files, got %d (%v)", repaired, err)
+	}
+	if repaired, err := reopened.Scrub(2); err != nil || repaired != 0 {
+		t.Errorf("Scrub: Expected nothing left to repair, got %d (%v)", repaired, err)
+	}
+
+	// A corrupt shard is detected by its hash and rebuilt like a missing one
+	if err := os.WriteFile(reopened.shardPath(2, 1), []byte("bit rot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := reopened.Get(2); err != nil || !bytes.Equal(data, blobs[2]) {
+		t.Errorf("Get: Expected blob despite a corrupt shard, got %v", err)
+	}
+
+	// Losing more shards than there are parity shards loses the blob
+	for shard := 0; shard < 3; shard++ {
+		os.Remove(reopened.shardPath(0, shard))
+	}
+	if _, err := reopened.Get(0); !errors.Is(err, ErrTooFewShards) {
+		t.Errorf("Get: Expected ErrTooFewShards, got %v", err)
+	}
+	if _, err := reopened.Get(3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get: Expected ErrNotFound, got %v", err)
+	}
+}
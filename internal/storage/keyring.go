@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ErrKeyringLocked is returned by LockKeyring when another process holds the lock
+var ErrKeyringLocked = errors.New("keyring is in use by another process")
+
+// LockKeyring takes an exclusive lock on the keyring at path, held until
+// the returned function is called or the process exits. The server holds it
+// while it runs, so that keys are never rotated under a server that keeps
+// wrapping data keys with the old primary key.
+func LockKeyring(path string) (func() error, error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening keyring lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f.Close, nil
+}
+
+// Keyring holds the key encryption keys of an EncryptedStore. New data keys
+// are wrapped with the primary key; older keys stay until every data key
+// has been re-wrapped.
+type Keyring struct {
+	path    string
+	primary string
+	keys    map[string][]byte
+}
+
+type keyringFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LoadOrCreateKeyring reads the keyring at path, creating one with a single
+// new key if the file does not exist
+func LoadOrCreateKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		keyring := &Keyring{path: path, keys: map[string][]byte{}}
+		if _, err := keyring.Rotate(); err != nil {
+			return nil, err
+		}
+		return keyring, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading keyring: %w", err)
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding keyring %s: %w", path, err)
+	}
+	keyring := &Keyring{path: path, primary: file.Primary, keys: map[string][]byte{}}
+	for id, encoded := range file.Keys {
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keyring %s: key %q is not a hex-encoded 32-byte key", path, id)
+		}
+		keyring.keys[id] = key
+	}
+	if keyring.keys[file.Primary] == nil {
+		return nil, fmt.Errorf("keyring %s: primary key %q is missing", path, file.Primary)
+	}
+	return keyring, nil
+}
+
+// Primary returns the ID and value of the key new data keys are wrapped with
+func (k *Keyring) Primary() (string, []byte) {
+	return k.primary, k.keys[k.primary]
+}
+
+// Key returns the key with the given ID
+func (k *Keyring) Key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key %q is not in the keyring", id)
+	}
+	return key, nil
+}
+
+// Rotate adds a new key, makes it the primary key and saves the keyring.
+// It returns the new key's ID.
+func (k *Keyring) Rotate() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	next := 1
+	for id := range k.keys {
+		if n, err := strconv.Atoi(id); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	id := strconv.Itoa(next)
+	k.keys[id] = key
+	k.primary = id
+	return id, k.save()
+}
+
+// Retire removes every key but the primary one and saves the keyring. Call
+// it only after EncryptedStore.Rewrap has succeeded.
+func (k *Keyring) Retire() error {
+	for id := range k.keys {
+		if id != k.primary {
+			delete(k.keys, id)
+		}
+	}
+	return k.save()
+}
+
+func (k *Keyring) save() error {
+	file := keyringFile{Primary: k.primary, Keys: map[string]string{}}
+	for id, key := range k.keys {
+		file.Keys[id] = hex.EncodeToString(key)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	// WriteFileAtomic creates the file readable by its owner only
+	if err := WriteFileAtomic(k.path, data); err != nil {
+		return fmt.Errorf("saving keyring: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile fails where advisory file locks aren't available
+func lockFile(f *os.File) error {
+	return errors.New("file locking is not supported on this platform")
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooFewShards is returned when fewer intact shards remain than are needed to rebuild a blob
+var ErrTooFewShards = errors.New("too few intact shards to reconstruct")
+
+// gfExp and gfLog are exponent and logarithm tables of GF(2^8) with the
+// polynomial x^8+x^4+x^3+x^2+1. gfExp is doubled so products need no modulo.
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// reedSolomon is a systematic Reed-Solomon code over GF(2^8). Its encoding
+// matrix stacks the identity on a Cauchy matrix, so any data rows of it are
+// invertible and any data shards out of data+parity rebuild the rest.
+type reedSolomon struct {
+	data, parity int
+	matrix       [][]byte
+}
+
+func newReedSolomon(data, parity int) (*reedSolomon, error) {
+	if data < 1 || parity < 0 || data+parity > 256 {
+		return nil, fmt.Errorf("invalid erasure coding of %d data and %d parity shards", data, parity)
+	}
+
+	matrix := make([][]byte, data+parity)
+	for i := range matrix {
+		matrix[i] = make([]byte, data)
+		if i < data {
+			matrix[i][i] = 1
+			continue
+		}
+		for j := range matrix[i] {
+			// The Cauchy element 1/(x_i + y_j) with x_i = i and y_j = j, all distinct
+			matrix[i][j] = gfInv(byte(i) ^ byte(j))
+		}
+	}
+	return &reedSolomon{data: data, parity: parity, matrix: matrix}, nil
+}
+
+// encode splits blob into data shards of equal size, zero-padding the last
+// one, and appends the parity shards
+func (rs *reedSolomon) encode(blob []byte) [][]byte {
+	size := (len(blob) + rs.data - 1) / rs.data
+	padded := make([]byte, size*rs.data)
+	copy(padded, blob)
+
+	shards := make([][]byte, rs.data+rs.parity)
+	for i := 0; i < rs.data; i++ {
+		shards[i] = padded[i*size : (i+1)*size]
+	}
+	for i := rs.data; i < len(shards); i++ {
+		shards[i] = rs.combine(rs.matrix[i], shards[:rs.data], size)
+	}
+	return shards
+}
+
+// reconstruct rebuilds the nil entries of shards from the intact ones
+func (rs *reedSolomon) reconstruct(shards [][]byte) error {
+	if len(shards) != rs.data+rs.parity {
+		return fmt.Errorf("expected %d shards, got %d", rs.data+rs.parity, len(shards))
+	}
+
+	var rows [][]byte
+	var present [][]byte
+	for i, shard := range shards {
+		if shard != nil && len(rows) < rs.data {
+			rows = append(rows, rs.matrix[i])
+			present = append(present, shard)
+		}
+	}
+	if len(rows) < rs.data {
+		return fmt.Errorf("%w: %d of %d needed", ErrTooFewShards, len(rows), rs.data)
+	}
+	size := len(present[0])
+
+	decode := invert(rows)
+	for i := 0; i < rs.data; i++ {
+		if shards[i] == nil {
+			shards[i] = rs.combine(decode[i], present, size)
+		}
+	}
+	for i := rs.data; i < len(shards); i++ {
+		if shards[i] == nil {
+			shards[i] = rs.combine(rs.matrix[i], shards[:rs.data], size)
+		}
+	}
+	return nil
+}
+
+// combine returns the linear combination of inputs with the given coefficients
+func (rs *reedSolomon) combine(coefficients []byte, inputs [][]byte, size int) []byte {
+	out := make([]byte, size)
+	for j, c := range coefficients {
+		if c == 0 {
+			continue
+		}
+		for p, b := range inputs[j] {
+			out[p] ^= gfMul(c, b)
+		}
+	}
+	return out
+}
+
+// invert inverts a square matrix by Gauss-Jordan elimination. The rows taken
+// from the encoding matrix are always invertible.
+func invert(matrix [][]byte) [][]byte {
+	n := len(matrix)
+	work := make([][]byte, n)
+	for i := range work {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], matrix[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for work[pivot][col] == 0 {
+			pivot++
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for j := range work[col] {
+			work[col][j] = gfMul(work[col][j], scale)
+		}
+		for i := range work {
+			if i == col || work[i][col] == 0 {
+				continue
+			}
+			factor := work[i][col]
+			for j := range work[i] {
+				work[i][j] ^= gfMul(factor, work[col][j])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+	return inverse
+}
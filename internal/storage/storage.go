@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when no blob is stored at an index
+var ErrNotFound = errors.New("blob not found")
+
+// Store persists uploaded file contents by index
+type Store interface {
+	Put(index int, data []byte) error
+	Get(index int) ([]byte, error)
+	Len() int
+}
+
+// DiskStore keeps one file per blob in a directory
+type DiskStore struct {
+	dir   string
+	count int
+}
+
+// blobSuffix is the extension of blob files in a DiskStore
+const blobSuffix = ".blob"
+
+// NewDiskStore opens or creates a store in dir and counts the blobs already present
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading store directory: %w", err)
+	}
+
+	var indices []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, blobSuffix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(name, blobSuffix))
+		if err != nil {
+			continue
+		}
+		indices = append(indices, index)
+	}
+
+	sort.Ints(indices)
+	for i, index := range indices {
+		if index != i {
+			return nil, fmt.Errorf("store is missing blob %d", i)
+		}
+	}
+	return &DiskStore{dir: dir, count: len(indices)}, nil
+}
+
+func (s *DiskStore) path(index int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d%s", index, blobSuffix))
+}
+
+// Put writes the blob atomically. Blobs are append-only, so index must be the next free index.
+func (s *DiskStore) Put(index int, data []byte) error {
+	if index != s.count {
+		return fmt.Errorf("expected blob %d, got %d", s.count, index)
+	}
+	if err := WriteFileAtomic(s.path(index), data); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Get reads the blob stored at index
+func (s *DiskStore) Get(index int) ([]byte, error) {
+	if index < 0 || index >= s.count {
+		return nil, ErrNotFound
+	}
+	return os.ReadFile(s.path(index))
+}
+
+// Len returns the number of stored blobs
+func (s *DiskStore) Len() int {
+	return s.count
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it into place,
+// so readers never observe a partially written file.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
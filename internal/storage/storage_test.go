@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatalf("NewDiskStore: Unexpected error: %v", err)
+	}
+
+	if err := store.Put(0, []byte("first")); err != nil {
+		t.Fatalf("Put: Unexpected error: %v", err)
+	}
+	if err := store.Put(1, []byte("second")); err != nil {
+		t.Fatalf("Put: Unexpected error: %v", err)
+	}
+	if err := store.Put(5, []byte("gap")); err == nil {
+		t.Error("Put: Expected error for non-sequential index, got nil")
+	}
+
+	reopened, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatalf("NewDiskStore: Unexpected error on reopen: %v", err)
+	}
+	if reopened.Len() != 2 {
+		t.Errorf("Len: Expected 2 blobs after reopen, got %d", reopened.Len())
+	}
+
+	data, err := reopened.Get(1)
+	if err != nil || !bytes.Equal(data, []byte("second")) {
+		t.Errorf("Get: Expected second blob, got %q (%v)", data, err)
+	}
+	if _, err := reopened.Get(2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get: Expected ErrNotFound, got %v", err)
+	}
+}
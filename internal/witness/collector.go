@@ -0,0 +1,149 @@
+package witness
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// Log is the server side of cosigning: it supplies tree heads and
+// consistency proofs and keeps the collected cosignatures
+type Log interface {
+	GetSignedTreeHead() (*treehead.SignedTreeHead, error)
+	GetConsistencyProof(oldSize, newSize int) ([][32]byte, error)
+	AddCosignatures(sth *treehead.SignedTreeHead, cosignatures []treehead.Cosignature)
+}
+
+// Collector sends a log's tree heads to its witnesses and attaches the
+// returned cosignatures to the log's current head
+type Collector struct {
+	log       Log
+	witnesses []string
+	// sizes remembers each witness's latest cosigned tree size
+	sizes map[string]int
+}
+
+// NewCollector creates a collector for the witnesses at the given base URLs
+func NewCollector(log Log, witnessURLs []string) *Collector {
+	return &Collector{log: log, witnesses: witnessURLs, sizes: make(map[string]int)}
+}
+
+// Run collects cosignatures every interval until ctx is cancelled
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Collect(); err != nil {
+			log.Printf("Collecting cosignatures: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect requests a cosignature on the current tree head from every
+// witness. Cosignatures that were obtained are attached even if some
+// witnesses failed; the failures are returned together.
+func (c *Collector) Collect() error {
+	sth, err := c.log.GetSignedTreeHead()
+	if err != nil {
+		return err
+	}
+	if len(sth.Cosignatures) == len(c.witnesses) {
+		return nil
+	}
+
+	var cosignatures []treehead.Cosignature
+	var errs []error
+	for _, url := range c.witnesses {
+		cosig, err := c.cosign(url, sth)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("witness %s: %w", url, err))
+			continue
+		}
+		cosignatures = append(cosignatures, cosig)
+	}
+
+	c.log.AddCosignatures(sth, cosignatures)
+	return errors.Join(errs...)
+}
+
+// cosign sends sth to one witness, retrying once if the witness reports a different latest size
+func (c *Collector) cosign(url string, sth *treehead.SignedTreeHead) (treehead.Cosignature, error) {
+	cosig, err := c.request(url, sth, c.sizes[url])
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		c.sizes[url] = conflict.TreeSize
+		cosig, err = c.request(url, sth, conflict.TreeSize)
+	}
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+	c.sizes[url] = sth.TreeSize
+	return cosig, nil
+}
+
+// request posts sth with a consistency proof from oldSize to the witness
+func (c *Collector) request(url string, sth *treehead.SignedTreeHead, oldSize int) (treehead.Cosignature, error) {
+	if oldSize > sth.TreeSize {
+		return treehead.Cosignature{}, fmt.Errorf("witness has cosigned tree size %d, larger than %d", oldSize, sth.TreeSize)
+	}
+
+	request := CosignRequest{TreeHead: sth, OldSize: oldSize, Proof: []string{}}
+	if oldSize > 0 && oldSize < sth.TreeSize {
+		proof, err := c.log.GetConsistencyProof(oldSize, sth.TreeSize)
+		if err != nil {
+			return treehead.Cosignature{}, err
+		}
+		for _, hash := range proof {
+			request.Proof = append(request.Proof, hex.EncodeToString(hash[:]))
+		}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+	resp, err := http.Post(url+"/cosign", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var cosig treehead.Cosignature
+		if err := json.NewDecoder(resp.Body).Decode(&cosig); err != nil {
+			return treehead.Cosignature{}, err
+		}
+		key, err := treehead.ParsePublicKey(cosig.WitnessKey)
+		if err != nil {
+			return treehead.Cosignature{}, err
+		}
+		if err := cosig.Verify(key, sth); err != nil {
+			return treehead.Cosignature{}, err
+		}
+		return cosig, nil
+	case http.StatusConflict:
+		var conflict conflictResponse
+		if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+			return treehead.Cosignature{}, err
+		}
+		return treehead.Cosignature{}, &ConflictError{TreeSize: conflict.TreeSize}
+	default:
+		return treehead.Cosignature{}, fmt.Errorf("cosign request failed: %s", resp.Status)
+	}
+}
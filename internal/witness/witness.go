@@ -0,0 +1,193 @@
+package witness
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/storage"
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+// ErrInconsistent is returned when a tree head does not extend the last head the witness cosigned
+var ErrInconsistent = errors.New("tree head is not consistent with the last cosigned head")
+
+// ConflictError is returned when the caller's old size is not the witness's
+// latest cosigned size. The caller should retry with a consistency proof
+// from TreeSize.
+type ConflictError struct {
+	TreeSize int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("witness has cosigned tree size %d", e.TreeSize)
+}
+
+// CosignRequest asks a witness to cosign TreeHead. Proof is a consistency
+// proof from OldSize, which must be the witness's latest cosigned size.
+type CosignRequest struct {
+	TreeHead *treehead.SignedTreeHead `json:"treeHead"`
+	OldSize  int                      `json:"oldSize"`
+	Proof    []string                 `json:"proof"`
+}
+
+// conflictResponse is returned with 409 Conflict when OldSize is stale
+type conflictResponse struct {
+	TreeSize int `json:"treeSize"`
+}
+
+// Witness cosigns tree heads of a single log, refusing any head that does
+// not extend the last one it cosigned. Its latest cosigned head is persisted
+// so that a restart cannot be used to make it forget.
+type Witness struct {
+	key       ed25519.PrivateKey
+	logKey    ed25519.PublicKey
+	statePath string
+
+	mu sync.Mutex
+}
+
+// New creates a witness for the log whose tree heads are signed by logKey
+func New(key ed25519.PrivateKey, logKey ed25519.PublicKey, statePath string) *Witness {
+	return &Witness{key: key, logKey: logKey, statePath: statePath}
+}
+
+// Latest returns the last tree head the witness cosigned, or nil if it has not cosigned one yet
+func (w *Witness) Latest() (*treehead.SignedTreeHead, error) {
+	data, err := os.ReadFile(w.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading witness state: %w", err)
+	}
+
+	var sth treehead.SignedTreeHead
+	if err := json.Unmarshal(data, &sth); err != nil {
+		return nil, fmt.Errorf("decoding witness state: %w", err)
+	}
+	return &sth, nil
+}
+
+// Cosign verifies that sth is signed by the log and extends the latest
+// cosigned head, then cosigns it and records it as the latest head.
+func (w *Witness) Cosign(sth *treehead.SignedTreeHead, oldSize int, proof [][32]byte) (treehead.Cosignature, error) {
+	if err := sth.Verify(w.logKey); err != nil {
+		return treehead.Cosignature{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	latest, err := w.Latest()
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+	if latest != nil {
+		if err := checkConsistency(latest, sth, oldSize, proof); err != nil {
+			return treehead.Cosignature{}, err
+		}
+	} else if oldSize != 0 {
+		return treehead.Cosignature{}, &ConflictError{TreeSize: 0}
+	}
+
+	cosig, err := treehead.Cosign(w.key, sth, time.Now())
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+
+	head := *sth
+	head.Cosignatures = nil
+	data, err := json.Marshal(head)
+	if err != nil {
+		return treehead.Cosignature{}, err
+	}
+	if err := storage.WriteFileAtomic(w.statePath, data); err != nil {
+		return treehead.Cosignature{}, fmt.Errorf("saving witness state: %w", err)
+	}
+	return cosig, nil
+}
+
+// checkConsistency verifies that sth extends latest using a proof from oldSize
+func checkConsistency(latest, sth *treehead.SignedTreeHead, oldSize int, proof [][32]byte) error {
+	if oldSize != latest.TreeSize {
+		return &ConflictError{TreeSize: latest.TreeSize}
+	}
+	if sth.TreeSize < latest.TreeSize {
+		return fmt.Errorf("%w: tree size %d is smaller than %d", ErrInconsistent, sth.TreeSize, latest.TreeSize)
+	}
+	if sth.Timestamp < latest.Timestamp {
+		return fmt.Errorf("%w: tree head is older than the last cosigned head", ErrInconsistent)
+	}
+
+	latestRoot, err := latest.Root()
+	if err != nil {
+		return err
+	}
+	root, err := sth.Root()
+	if err != nil {
+		return err
+	}
+	if sth.TreeSize == latest.TreeSize {
+		if root != latestRoot {
+			return fmt.Errorf("%w: different root for tree size %d", ErrInconsistent, sth.TreeSize)
+		}
+		return nil
+	}
+	if latest.TreeSize == 0 {
+		return nil
+	}
+	if !merkle.VerifyConsistency(latest.TreeSize, sth.TreeSize, latestRoot, root, proof) {
+		return fmt.Errorf("%w: consistency proof from %d to %d does not verify", ErrInconsistent, latest.TreeSize, sth.TreeSize)
+	}
+	return nil
+}
+
+// CosignHandler serves POST /cosign
+func (w *Witness) CosignHandler(rw http.ResponseWriter, r *http.Request) {
+	var request CosignRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.TreeHead == nil {
+		http.Error(rw, "Invalid cosign request", http.StatusBadRequest)
+		return
+	}
+
+	proof := make([][32]byte, len(request.Proof))
+	for i, encoded := range request.Proof {
+		hash, err := hex.DecodeString(encoded)
+		if err != nil || len(hash) != 32 {
+			http.Error(rw, "Invalid proof hash", http.StatusBadRequest)
+			return
+		}
+		proof[i] = [32]byte(hash)
+	}
+	if err := request.TreeHead.Verify(w.logKey); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cosig, err := w.Cosign(request.TreeHead, request.OldSize, proof)
+	var conflict *ConflictError
+	switch {
+	case errors.As(err, &conflict):
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusConflict)
+		json.NewEncoder(rw).Encode(conflictResponse{TreeSize: conflict.TreeSize})
+		return
+	case errors.Is(err, ErrInconsistent):
+		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(cosig)
+}
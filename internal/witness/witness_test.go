@@ -0,0 +1,127 @@
+package witness
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/internal/server"
+	"github.com/akhilesharora/go-merkle/pkg/treehead"
+)
+
+func newLog(key ed25519.PrivateKey, files ...string) *server.Server {
+	srv := server.NewServer()
+	srv.SigningKey = key
+	for _, file := range files {
+		srv.UploadFile(file, []byte(file))
+	}
+	return srv
+}
+
+func newWitness(t *testing.T, logKey ed25519.PublicKey) (*Witness, ed25519.PublicKey, string) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	w := New(private, logKey, filepath.Join(t.TempDir(), "state.json"))
+	ts := httptest.NewServer(http.HandlerFunc(w.CosignHandler))
+	t.Cleanup(ts.Close)
+	return w, public, ts.URL
+}
+
+func TestCollectCosignatures(t *testing.T) {
+	logPublic, logKey, _ := ed25519.GenerateKey(nil)
+	srv := newLog(logKey, "a", "b", "c")
+
+	var keys []ed25519.PublicKey
+	var urls []string
+	for i := 0; i < 2; i++ {
+		_, key, url := newWitness(t, logPublic)
+		keys = append(keys, key)
+		urls = append(urls, url)
+	}
+
+	collector := NewCollector(srv, urls)
+	if err := collector.Collect(); err != nil {
+		t.Fatalf("Collect: Unexpected error: %v", err)
+	}
+	sth, _ := srv.GetSignedTreeHead()
+	if err := sth.VerifyQuorum(keys, 2); err != nil {
+		t.Fatalf("Collect: Tree head is missing cosignatures: %v", err)
+	}
+
+	// Growing the tree requires consistency proofs from the witnesses' last heads
+	for _, file := range []string{"d", "e", "f", "g"} {
+		srv.UploadFile(file, []byte(file))
+	}
+	if sth, _ := srv.GetSignedTreeHead(); len(sth.Cosignatures) != 0 {
+		t.Fatal("GetSignedTreeHead: A new tree head should start without cosignatures")
+	}
+	if err := collector.Collect(); err != nil {
+		t.Fatalf("Collect: Unexpected error: %v", err)
+	}
+	sth, _ = srv.GetSignedTreeHead()
+	if err := sth.VerifyQuorum(keys, 2); err != nil || sth.TreeSize != 7 {
+		t.Fatalf("Collect: Grown tree head is missing cosignatures: %v", err)
+	}
+
+	// A collector that doesn't know the witnesses' sizes learns them from the conflict response
+	srv.UploadFile("h", []byte("h"))
+	if err := NewCollector(srv, urls).Collect(); err != nil {
+		t.Fatalf("Collect: Unexpected error with a fresh collector: %v", err)
+	}
+	sth, _ = srv.GetSignedTreeHead()
+	if err := sth.VerifyQuorum(keys, 2); err != nil {
+		t.Errorf("Collect: Fresh collector didn't get cosignatures: %v", err)
+	}
+}
+
+func TestWitnessRefusesSplitView(t *testing.T) {
+	logPublic, logKey, _ := ed25519.GenerateKey(nil)
+	w, _, url := newWitness(t, logPublic)
+
+	if err := NewCollector(newLog(logKey, "a", "b", "c"), []string{url}).Collect(); err != nil {
+		t.Fatalf("Collect: Unexpected error: %v", err)
+	}
+
+	for name, fork := range map[string]*server.Server{
+		"same size": newLog(logKey, "a", "x", "c"),
+		"larger":    newLog(logKey, "a", "x", "c", "d"),
+		"rewind":    newLog(logKey, "a", "b"),
+	} {
+		if err := NewCollector(fork, []string{url}).Collect(); err == nil {
+			t.Errorf("%s: Collect: Expected the witness to refuse a forked tree head", name)
+		}
+		if sth, _ := fork.GetSignedTreeHead(); len(sth.Cosignatures) != 0 {
+			t.Errorf("%s: Forked tree head should not be cosigned", name)
+		}
+	}
+
+	latest, _ := w.Latest()
+	if latest == nil || latest.TreeSize != 3 {
+		t.Error("Latest: Witness state should still hold the first tree head")
+	}
+}
+
+func TestCosignChecks(t *testing.T) {
+	logPublic, logKey, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	w, _, _ := newWitness(t, logPublic)
+
+	if _, err := w.Cosign(treehead.Sign(otherKey, 1, [32]byte{1}, time.Now()), 0, nil); err == nil {
+		t.Error("Cosign: Expected error for a tree head signed by another log")
+	}
+	if _, err := w.Cosign(treehead.Sign(logKey, 1, [32]byte{1}, time.Now()), 1, nil); !errors.As(err, new(*ConflictError)) {
+		t.Errorf("Cosign: Expected ConflictError for a fresh witness, got %v", err)
+	}
+
+	sth := treehead.Sign(logKey, 1, [32]byte{1}, time.Now())
+	if _, err := w.Cosign(sth, 0, nil); err != nil {
+		t.Fatalf("Cosign: Unexpected error: %v", err)
+	}
+	older := treehead.Sign(logKey, 1, [32]byte{1}, time.Now().Add(-time.Hour))
+	if _, err := w.Cosign(older, 1, nil); !errors.Is(err, ErrInconsistent) {
+		t.Errorf("Cosign: Expected ErrInconsistent for an older tree head, got %v", err)
+	}
+}
@@ -2,14 +2,42 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	ServerHost string `env:"SERVER_HOST" env-default:"localhost" env-description:"Host for the server"`
-	ServerPort int    `env:"SERVER_PORT" env-default:"8080" env-description:"Port for the server"`
-	LogLevel   string `env:"LOG_LEVEL" env-default:"info" env-description:"Logging level"`
+	ServerHost          string        `env:"SERVER_HOST" env-default:"localhost" env-description:"Host for the server"`
+	ServerPort          int           `env:"SERVER_PORT" env-default:"8080" env-description:"Port for the server"`
+	LogLevel            string        `env:"LOG_LEVEL" env-default:"info" env-description:"Logging level"`
+	TreeMode            string        `env:"TREE_MODE" env-default:"binary" env-description:"Tree structure maintained by the server (binary or mmr)"`
+	DataDir             string        `env:"DATA_DIR" env-default:"" env-description:"Directory for stored files and the tree snapshot; empty keeps everything in memory"`
+	ErasureDirs         []string      `env:"ERASURE_DIRS" env-separator:"," env-description:"Comma-separated directories, ideally on separate disks, to spread erasure-coded shards of stored files across; empty stores whole files in DATA_DIR"`
+	ErasureDataShards   int           `env:"ERASURE_DATA_SHARDS" env-default:"4" env-description:"Number of data shards each stored file is split into"`
+	ErasureParityShards int           `env:"ERASURE_PARITY_SHARDS" env-default:"2" env-description:"Number of parity shards per file; this many shards can be lost or corrupt"`
+	EncryptionKeyring   string        `env:"ENCRYPTION_KEYRING" env-default:"" env-description:"Keyring file used to encrypt stored files at rest, created if missing; empty stores files unencrypted"`
+	Compression         string        `env:"COMPRESSION" env-default:"none" env-description:"Compression of stored files (none or gzip); leaf hashes are always over the uncompressed bytes"`
+	SnapshotInterval    time.Duration `env:"SNAPSHOT_INTERVAL" env-default:"1m" env-description:"How often the tree snapshot is saved while files are being added, so a restart after a crash only rehashes newer files; 0 only saves it on shutdown"`
+	SnapshotVerify      string        `env:"SNAPSHOT_VERIFY" env-default:"spot" env-description:"Tree snapshot validation on startup (spot or full)"`
+	SigningKeyFile      string        `env:"SIGNING_KEY_FILE" env-default:"" env-description:"File holding the hex Ed25519 seed used to sign tree heads; created if missing, empty uses a temporary key"`
+	APIKeys             []string      `env:"API_KEYS" env-separator:"," env-description:"Comma-separated API keys with their scopes, each written as key:scope+scope with scopes upload, read and admin, and optionally @namespace to restrict the key to one namespace"`
+	TokenSecret         string        `env:"TOKEN_SECRET" env-default:"" env-description:"Secret of at least 32 bytes that signs bearer tokens; with no API keys either the API is open to anyone"`
+	CORSOrigins         []string      `env:"CORS_ORIGINS" env-separator:"," env-description:"Comma-separated origins allowed to make cross-origin API requests, or * for any; empty allows none"`
+	APIToken            string        `env:"API_TOKEN" env-default:"" env-description:"API key or bearer token the client, and a follower replicating from its leader, send with every request"`
+	LeaderURL           string        `env:"LEADER_URL" env-default:"" env-description:"URL of the leader to replicate from; empty runs as a leader"`
+	LeaderKey           string        `env:"LEADER_PUBLIC_KEY" env-default:"" env-description:"Hex Ed25519 public key of the leader's tree heads"`
+	ReplicationInterval time.Duration `env:"REPLICATION_INTERVAL" env-default:"30s" env-description:"How often a follower polls the leader"`
+	MaxMergeDelay       time.Duration `env:"MAX_MERGE_DELAY" env-default:"1m" env-description:"Longest an upload may take to appear in a signed tree head, promised to the uploader"`
+	TimestampInterval   time.Duration `env:"TIMESTAMP_INTERVAL" env-default:"1s" env-description:"Length of a timestamping epoch; submitted hashes are added to the tree when it ends"`
+	EpochInterval       time.Duration `env:"EPOCH_INTERVAL" env-default:"0s" env-description:"Queue uploads and add them to the tree in epochs of this length; 0 adds every upload immediately"`
+	EpochMaxLeaves      int           `env:"EPOCH_MAX_LEAVES" env-default:"1000" env-description:"Seal an epoch early once this many uploads are queued; 0 means no limit"`
+	ScrubRate           float64       `env:"SCRUB_RATE" env-default:"1" env-description:"Stored files re-hashed per second by the integrity scrubber when DATA_DIR is set; 0 disables it"`
+	WitnessURLs         []string      `env:"WITNESS_URLS" env-separator:"," env-description:"Comma-separated base URLs of witnesses asked to cosign tree heads"`
+	WitnessInterval     time.Duration `env:"WITNESS_INTERVAL" env-default:"10s" env-description:"How often tree heads are sent to witnesses"`
+	WitnessKeyFile      string        `env:"WITNESS_KEY_FILE" env-default:"witness.key" env-description:"File holding the witness's hex Ed25519 seed; created if missing"`
+	WitnessStateFile    string        `env:"WITNESS_STATE_FILE" env-default:"witness_state.json" env-description:"File holding the last tree head the witness cosigned"`
+	LogPublicKey        string        `env:"LOG_PUBLIC_KEY" env-default:"" env-description:"Hex Ed25519 public key of the server whose tree heads the witness cosigns"`
 }
 
 func LoadConfig() (*Config, error) {
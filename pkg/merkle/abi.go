@@ -0,0 +1,250 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// abiType is a parsed Solidity elementary type
+type abiType struct {
+	kind string // address, uint, int, bool, bytes (fixed), dynbytes or string
+	size int    // bit size for integers, byte size for fixed bytes
+}
+
+func parseABIType(t string) (abiType, error) {
+	switch {
+	case t == "address":
+		return abiType{kind: "address", size: 20}, nil
+	case t == "bool":
+		return abiType{kind: "bool"}, nil
+	case t == "string":
+		return abiType{kind: "string"}, nil
+	case t == "bytes":
+		return abiType{kind: "dynbytes"}, nil
+	case t == "uint" || t == "int":
+		return abiType{kind: t, size: 256}, nil
+	case strings.HasPrefix(t, "uint"), strings.HasPrefix(t, "int"):
+		kind := "int"
+		if t[0] == 'u' {
+			kind = "uint"
+		}
+		size, err := strconv.Atoi(strings.TrimPrefix(t, kind))
+		if err != nil || size < 8 || size > 256 || size%8 != 0 {
+			return abiType{}, fmt.Errorf("invalid type %q", t)
+		}
+		return abiType{kind: kind, size: size}, nil
+	case strings.HasPrefix(t, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		if err != nil || size < 1 || size > 32 {
+			return abiType{}, fmt.Errorf("invalid type %q", t)
+		}
+		return abiType{kind: "bytes", size: size}, nil
+	}
+	return abiType{}, fmt.Errorf("unsupported type %q", t)
+}
+
+func (t abiType) dynamic() bool {
+	return t.kind == "string" || t.kind == "dynbytes"
+}
+
+// EncodePacked encodes values like Solidity's abi.encodePacked: every value
+// takes only the bytes its type needs and dynamic values are not length-prefixed.
+func EncodePacked(types []string, values []interface{}) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("got %d values for %d types", len(values), len(types))
+	}
+
+	var out []byte
+	for i, name := range types {
+		t, err := parseABIType(name)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := t.raw(values[i])
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		out = append(out, raw...)
+	}
+	return out, nil
+}
+
+// Encode encodes values like Solidity's abi.encode: each value takes a
+// 32-byte head slot, and string and bytes values are stored after the heads
+// with their length.
+func Encode(types []string, values []interface{}) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("got %d values for %d types", len(values), len(types))
+	}
+
+	var head, tail []byte
+	for i, name := range types {
+		t, err := parseABIType(name)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := t.raw(values[i])
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+
+		if !t.dynamic() {
+			head = append(head, t.pad(raw)...)
+			continue
+		}
+		head = append(head, abiWord(big.NewInt(int64(32*len(types)+len(tail))))...)
+		tail = append(tail, abiWord(big.NewInt(int64(len(raw))))...)
+		tail = append(tail, rightPad(raw)...)
+	}
+	return append(head, tail...), nil
+}
+
+// raw converts a value into the minimal big-endian bytes of the type
+func (t abiType) raw(value interface{}) ([]byte, error) {
+	switch t.kind {
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %T", value)
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", value)
+		}
+		return []byte(s), nil
+	case "dynbytes":
+		return abiBytes(value)
+	case "address", "bytes":
+		b, err := abiBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != t.size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", t.size, len(b))
+		}
+		return b, nil
+	default:
+		n, err := abiInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		return t.integerBytes(n)
+	}
+}
+
+// integerBytes checks n fits the integer type and returns it in two's complement
+func (t abiType) integerBytes(n *big.Int) ([]byte, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size))
+	if t.kind == "int" {
+		half := new(big.Int).Rsh(limit, 1)
+		if n.Cmp(half) >= 0 || n.Cmp(new(big.Int).Neg(half)) < 0 {
+			return nil, fmt.Errorf("%s out of range for int%d", n, t.size)
+		}
+		if n.Sign() < 0 {
+			n = new(big.Int).Add(n, limit)
+		}
+	} else if n.Sign() < 0 || n.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("%s out of range for uint%d", n, t.size)
+	}
+	return n.FillBytes(make([]byte, t.size/8)), nil
+}
+
+// pad widens the raw bytes of a static value to a 32-byte word
+func (t abiType) pad(raw []byte) []byte {
+	word := make([]byte, 32)
+	switch {
+	case t.kind == "bytes":
+		copy(word, raw)
+	case t.kind == "int" && raw[0]&0x80 != 0:
+		for i := range word {
+			word[i] = 0xff
+		}
+		copy(word[32-len(raw):], raw)
+	default:
+		copy(word[32-len(raw):], raw)
+	}
+	return word
+}
+
+func abiWord(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+func rightPad(b []byte) []byte {
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return padded
+}
+
+// abiBytes accepts a byte slice, a fixed-size byte array or a 0x-prefixed hex string
+func abiBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case [20]byte:
+		return v[:], nil
+	case [32]byte:
+		return v[:], nil
+	case string:
+		if !strings.HasPrefix(v, "0x") {
+			return nil, fmt.Errorf("expected 0x-prefixed hex, got %q", v)
+		}
+		return hex.DecodeString(v[2:])
+	}
+	return nil, fmt.Errorf("expected bytes, got %T", value)
+}
+
+// abiInteger accepts Go integers, *big.Int and decimal or 0x-prefixed hex strings
+func abiInteger(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case float64:
+		// Numbers decoded from JSON
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("%v is not an integer", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", v)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("expected integer, got %T", value)
+}
+
+// StandardLeafHash returns the leaf hasher used by OpenZeppelin's
+// StandardMerkleTree: keccak256(keccak256(abi.encode(value))). The double
+// hash keeps leaves from being confused with 64-byte internal nodes.
+func StandardLeafHash(leafEncoding []string) LeafHasher {
+	return func(value []interface{}) ([32]byte, error) {
+		encoded, err := Encode(leafEncoding, value)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		inner := Keccak256(encoded)
+		return Keccak256(inner[:]), nil
+	}
+}
+
+// NewOpenZeppelinTree builds a StandardTree whose root, proofs and dump match
+// OpenZeppelin's StandardMerkleTree.of(values, leafEncoding).
+func NewOpenZeppelinTree(values [][]interface{}, leafEncoding []string) (*StandardTree, error) {
+	return NewStandardTree(values, leafEncoding, StandardLeafHash(leafEncoding), Keccak256)
+}
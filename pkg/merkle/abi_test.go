@@ -0,0 +1,101 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := map[string]string{
+		"":    "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		"abc": "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+		// Function selector of ERC-20 transfer, a9059cbb
+		"transfer(address,uint256)": "a9059cbb2ab09eb219583f4a59a5d0623ade346d962bcd4e46b11da047c9049b",
+	}
+	for input, want := range tests {
+		hash := Keccak256([]byte(input))
+		if got := hex.EncodeToString(hash[:]); got != want {
+			t.Errorf("Keccak256(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestEncodePacked(t *testing.T) {
+	encoded, err := EncodePacked(
+		[]string{"address", "uint16", "bool", "bytes2", "string"},
+		[]interface{}{"0x1111111111111111111111111111111111111111", 258, true, "0xabcd", "hi"},
+	)
+	if err != nil {
+		t.Fatalf("EncodePacked returned an error: %v", err)
+	}
+
+	want := "1111111111111111111111111111111111111111" + "0102" + "01" + "abcd" + "6869"
+	if got := hex.EncodeToString(encoded); got != want {
+		t.Errorf("EncodePacked = %s, want %s", got, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	encoded, err := Encode([]string{"int8", "string"}, []interface{}{-1, "hi"})
+	if err != nil {
+		t.Fatalf("Encode returned an error: %v", err)
+	}
+
+	want := "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"0000000000000000000000000000000000000000000000000000000000000002" +
+		"6869000000000000000000000000000000000000000000000000000000000000"
+	if got := hex.EncodeToString(encoded); got != want {
+		t.Errorf("Encode = %s, want %s", got, want)
+	}
+}
+
+func TestEncodeRejectsInvalidValues(t *testing.T) {
+	cases := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"uint8", 256},
+		{"uint256", -1},
+		{"int8", 128},
+		{"address", "0x1234"},
+		{"bool", "true"},
+		{"uint7", 1},
+	}
+	for _, c := range cases {
+		if _, err := EncodePacked([]string{c.typ}, []interface{}{c.value}); err == nil {
+			t.Errorf("EncodePacked(%s, %v) should return an error", c.typ, c.value)
+		}
+	}
+}
+
+// The root below is the one printed by StandardMerkleTree.of in the
+// @openzeppelin/merkle-tree README for the same values.
+func TestOpenZeppelinTreeRoot(t *testing.T) {
+	values := [][]interface{}{
+		{"0x1111111111111111111111111111111111111111", "5000000000000000000"},
+		{"0x2222222222222222222222222222222222222222", "2500000000000000000"},
+	}
+	tree, err := NewOpenZeppelinTree(values, []string{"address", "uint256"})
+	if err != nil {
+		t.Fatalf("NewOpenZeppelinTree returned an error: %v", err)
+	}
+
+	root := tree.Root()
+	want := "d4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77"
+	if got := hex.EncodeToString(root[:]); got != want {
+		t.Errorf("root = %s, want %s", got, want)
+	}
+
+	leafHash := StandardLeafHash(tree.LeafEncoding)
+	for i, value := range values {
+		leaf, _ := leafHash(value)
+		proof, err := tree.GenerateProof(i)
+		if err != nil {
+			t.Fatalf("GenerateProof returned an error: %v", err)
+		}
+		if !VerifySortedProof(Keccak256, leaf, proof, root) {
+			t.Errorf("proof for value %d did not verify", i)
+		}
+	}
+}
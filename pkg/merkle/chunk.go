@@ -0,0 +1,23 @@
+package merkle
+
+// ChunkSize is the size of the chunks a file is split into for storage audits
+const ChunkSize = 4096
+
+// SplitChunks splits data into chunks of ChunkSize bytes. The chunks share
+// data's backing array. An empty file is a single empty chunk.
+func SplitChunks(data []byte) [][]byte {
+	if len(data) == 0 {
+		return [][]byte{{}}
+	}
+
+	chunks := make([][]byte, 0, (len(data)+ChunkSize-1)/ChunkSize)
+	for start := 0; start < len(data); start += ChunkSize {
+		chunks = append(chunks, data[start:min(start+ChunkSize, len(data))])
+	}
+	return chunks
+}
+
+// BuildChunkTree builds a Merkle tree over the chunks of a file
+func BuildChunkTree(data []byte) *Tree[[]byte] {
+	return BuildTree(SplitChunks(data), EncodeBytes)
+}
@@ -0,0 +1,20 @@
+package merkle
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 2*ChunkSize+10)
+	chunks := SplitChunks(data)
+	if len(chunks) != 3 || len(chunks[2]) != 10 {
+		t.Fatalf("Expected 3 chunks with a 10 byte tail, got %d", len(chunks))
+	}
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Error("Chunks should join back into the data")
+	}
+	if chunks := SplitChunks(nil); len(chunks) != 1 || len(chunks[0]) != 0 {
+		t.Error("An empty file should be a single empty chunk")
+	}
+}
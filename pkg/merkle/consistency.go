@@ -0,0 +1,126 @@
+package merkle
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// ConsistencyProof proves that the tree of oldSize leaves is a prefix of the
+// given tree. The proof holds the tree's frontier at oldSize (the roots of the
+// perfect subtrees that make up the old tree, largest first) followed by the
+// right-hand siblings needed to climb from the smallest of those subtrees to
+// the root of the given tree.
+func ConsistencyProof(tree NodeSource, oldSize int) ([][32]byte, error) {
+	newSize, err := tree.TreeSize()
+	if err != nil {
+		return nil, err
+	}
+	if oldSize < 1 || oldSize > newSize {
+		return nil, fmt.Errorf("old size %d out of range for tree of size %d", oldSize, newSize)
+	}
+
+	var proof [][32]byte
+	for h := bits.Len(uint(oldSize)) - 1; h >= 0; h-- {
+		if oldSize>>h&1 == 1 {
+			hashes, err := tree.NodeHashes(h, []int{oldSize>>h - 1})
+			if err != nil {
+				return nil, err
+			}
+			proof = append(proof, hashes[0])
+		}
+	}
+
+	sizes := levelSizes(newSize)
+	level := bits.TrailingZeros(uint(oldSize))
+	for index := oldSize>>level - 1; level < len(sizes)-1; level, index = level+1, index/2 {
+		if index%2 == 0 && index+1 < sizes[level] {
+			hashes, err := tree.NodeHashes(level, []int{index + 1})
+			if err != nil {
+				return nil, err
+			}
+			proof = append(proof, hashes[0])
+		}
+	}
+	return proof, nil
+}
+
+// VerifyConsistency checks a proof from ConsistencyProof that the tree with
+// newRoot and newSize leaves extends the tree with oldRoot and oldSize leaves.
+func VerifyConsistency(oldSize, newSize int, oldRoot, newRoot [32]byte, proof [][32]byte) bool {
+	if oldSize < 1 || oldSize > newSize {
+		return false
+	}
+	frontierSize := bits.OnesCount(uint(oldSize))
+	if len(proof) < frontierSize {
+		return false
+	}
+	frontier, siblings := proof[:frontierSize], proof[frontierSize:]
+
+	// climb walks from the smallest frontier subtree to the root of a tree of
+	// size leaves, taking left siblings from the frontier and right siblings
+	// from the proof, and reports whether every proof element was used.
+	climb := func(size int, siblings [][32]byte) ([32]byte, bool) {
+		sizes := levelSizes(size)
+		level := bits.TrailingZeros(uint(oldSize))
+		hash := frontier[len(frontier)-1]
+		next := len(frontier) - 2
+		for index := oldSize>>level - 1; level < len(sizes)-1; level, index = level+1, index/2 {
+			switch {
+			case index%2 == 1:
+				if next < 0 {
+					return hash, false
+				}
+				hash = HashPair(frontier[next][:], hash[:])
+				next--
+			case index+1 < sizes[level]:
+				if len(siblings) == 0 {
+					return hash, false
+				}
+				hash = HashPair(hash[:], siblings[0][:])
+				siblings = siblings[1:]
+			default:
+				hash = HashPair(hash[:], hash[:])
+			}
+		}
+		return hash, next == -1 && len(siblings) == 0
+	}
+
+	computedOld, ok := climb(oldSize, nil)
+	if !ok || computedOld != oldRoot {
+		return false
+	}
+	computedNew, ok := climb(newSize, siblings)
+	return ok && computedNew == newRoot
+}
+
+// VerifyProof checks a proof in the format returned by MerkleTree.GenerateProof
+// (siblings ordered from the root down, directions true where the proven node
+// is the left child) for leafHash against root.
+func VerifyProof(leafHash [32]byte, proof [][32]byte, directions []bool, root [32]byte) bool {
+	if len(proof) != len(directions) {
+		return false
+	}
+	hash := leafHash
+	for i := len(proof) - 1; i >= 0; i-- {
+		if directions[i] {
+			hash = HashPair(hash[:], proof[i][:])
+		} else {
+			hash = HashPair(proof[i][:], hash[:])
+		}
+	}
+	return hash == root
+}
+
+// VerifyProofAt checks a proof like VerifyProof and also that it proves the
+// leaf at index in a tree of size leaves, not some other leaf with the same hash.
+func VerifyProofAt(leafHash [32]byte, index, size int, proof [][32]byte, directions []bool, root [32]byte) bool {
+	if index < 0 || index >= size || len(proof) != len(levelSizes(size))-1 || len(directions) != len(proof) {
+		return false
+	}
+	for level := range proof {
+		if directions[len(directions)-1-level] != (index>>level&1 == 0) {
+			return false
+		}
+	}
+	return VerifyProof(leafHash, proof, directions, root)
+}
@@ -0,0 +1,96 @@
+package merkle
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestConsistencyProof(t *testing.T) {
+	files := testFiles(40)
+	for newSize := 1; newSize <= len(files); newSize++ {
+		tree := BuildMerkleTree(files[:newSize])
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			oldRoot := BuildMerkleTree(files[:oldSize]).Root.Hash
+
+			proof, err := ConsistencyProof(tree, oldSize)
+			if err != nil {
+				t.Fatalf("%d->%d: ConsistencyProof returned an error: %v", oldSize, newSize, err)
+			}
+			if !VerifyConsistency(oldSize, newSize, oldRoot, tree.Root.Hash, proof) {
+				t.Errorf("%d->%d: proof did not verify", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestConsistencyProofRejectsForks(t *testing.T) {
+	files := testFiles(13)
+	forked := append([]File{}, files...)
+	forked[2] = File{Data: "rewritten"}
+
+	tree := BuildMerkleTree(files)
+	oldRoot := BuildMerkleTree(forked[:6]).Root.Hash
+
+	proof, _ := ConsistencyProof(tree, 6)
+	if VerifyConsistency(6, 13, oldRoot, tree.Root.Hash, proof) {
+		t.Error("VerifyConsistency should reject a tree that rewrote an old leaf")
+	}
+
+	goodRoot := BuildMerkleTree(files[:6]).Root.Hash
+	if VerifyConsistency(6, 13, goodRoot, tree.Root.Hash, proof[:len(proof)-1]) {
+		t.Error("VerifyConsistency should reject a truncated proof")
+	}
+	if VerifyConsistency(6, 13, goodRoot, tree.Root.Hash, append(proof, [32]byte{})) {
+		t.Error("VerifyConsistency should reject a proof with extra hashes")
+	}
+	if VerifyConsistency(7, 6, goodRoot, tree.Root.Hash, proof) {
+		t.Error("VerifyConsistency should reject a shrinking tree")
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	files := testFiles(9)
+	tree := BuildMerkleTree(files)
+
+	for i, file := range files {
+		proof, directions, _ := tree.GenerateProof(i)
+		if !VerifyProof(CreateHash([]byte(file.Data)), proof, directions, tree.Root.Hash) {
+			t.Errorf("i=%d: proof did not verify", i)
+		}
+	}
+
+	proof, directions, _ := tree.GenerateProof(0)
+	if VerifyProof(CreateHash([]byte("other")), proof, directions, tree.Root.Hash) {
+		t.Error("VerifyProof should reject the wrong leaf")
+	}
+}
+
+func TestVerifyProofAt(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		data := make([]byte, 0, n*ChunkSize)
+		for i := 0; i < n; i++ {
+			data = append(data, bytes.Repeat([]byte{byte(i)}, ChunkSize)...)
+		}
+		tree := BuildChunkTree(data)
+		chunks := SplitChunks(data)
+
+		for i := 0; i < n; i++ {
+			proof, directions, _ := tree.GenerateProof(i)
+			leaf := CreateHash(chunks[i])
+			if !VerifyProofAt(leaf, i, n, proof, directions, tree.Root.Hash) {
+				t.Errorf("n=%d: proof for chunk %d should verify", n, i)
+			}
+			if n > 1 && VerifyProofAt(leaf, (i+1)%n, n, proof, directions, tree.Root.Hash) {
+				t.Errorf("n=%d: proof for chunk %d should not verify at another index", n, i)
+			}
+		}
+	}
+
+	// The duplicated last node has the same hash as the last chunk, so a proof
+	// for it must not pass as a proof for a chunk past the end
+	tree := BuildChunkTree(bytes.Repeat([]byte{1}, 3*ChunkSize))
+	proof, directions, _ := tree.GenerateProof(2)
+	if VerifyProofAt(CreateHash(bytes.Repeat([]byte{1}, ChunkSize)), 3, 3, proof, directions, tree.Root.Hash) {
+		t.Error("VerifyProofAt should reject an index outside the tree")
+	}
+}
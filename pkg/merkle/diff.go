@@ -0,0 +1,170 @@
+package merkle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NodeSource gives access to the node hashes of a tree, so that trees held
+// locally and trees on a remote server can be compared the same way. Levels
+// are numbered from the leaves (level 0) up to the root.
+type NodeSource interface {
+	// TreeSize returns the number of leaves in the tree
+	TreeSize() (int, error)
+	// NodeHashes returns the hashes of the nodes at the given indices on a level
+	NodeHashes(level int, indices []int) ([][32]byte, error)
+}
+
+// LeafRange is a half-open range [Start, End) of leaf indices
+type LeafRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Size returns the number of leaves in the tree
+func (t *Tree[T]) Size() int {
+	return len(t.Leaves)
+}
+
+// TreeSize implements NodeSource
+func (t *Tree[T]) TreeSize() (int, error) {
+	return len(t.Leaves), nil
+}
+
+// NodeHash returns the hash of the node at index on the given level, where
+// level 0 holds the leaves and the root is alone on the top level.
+func (t *Tree[T]) NodeHash(level, index int) ([32]byte, error) {
+	sizes := levelSizes(len(t.Leaves))
+	if level < 0 || level >= len(sizes) || index < 0 || index >= sizes[level] {
+		return [32]byte{}, fmt.Errorf("node %d on level %d out of range", index, level)
+	}
+
+	node := t.Root
+	for l := len(sizes) - 1; l > level; l-- {
+		if index>>(l-1-level)&1 == 0 {
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+	}
+	return node.Hash, nil
+}
+
+// NodeHashes implements NodeSource
+func (t *Tree[T]) NodeHashes(level int, indices []int) ([][32]byte, error) {
+	hashes := make([][32]byte, len(indices))
+	for i, index := range indices {
+		hash, err := t.NodeHash(level, index)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// Diff returns the ranges of leaves that differ between two trees, including
+// leaves present in only one of them. It walks both trees from the top, one
+// level at a time, and only descends into subtrees whose hashes differ, so the
+// number of hashes requested from each source grows with the number of
+// differences rather than the size of the trees.
+func Diff(a, b NodeSource) ([]LeafRange, error) {
+	sizeA, err := a.TreeSize()
+	if err != nil {
+		return nil, err
+	}
+	sizeB, err := b.TreeSize()
+	if err != nil {
+		return nil, err
+	}
+	size := max(sizeA, sizeB)
+	if size == 0 {
+		return nil, nil
+	}
+
+	var ranges []LeafRange
+	frontier := []int{0}
+	for level := len(levelSizes(size)) - 1; level >= 0 && len(frontier) > 0; level-- {
+		hashesA, err := fetchExisting(a, sizeA, level, frontier)
+		if err != nil {
+			return nil, err
+		}
+		hashesB, err := fetchExisting(b, sizeB, level, frontier)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int
+		for _, index := range frontier {
+			start, end := index<<level, min((index+1)<<level, size)
+			hashA, okA := hashesA[index]
+			hashB, okB := hashesB[index]
+
+			// Equal hashes only prove equal leaves when both sides cover the same
+			// leaves, since a duplicated last node hashes like a pair of equal leaves.
+			if okA && okB && hashA == hashB && min(end, sizeA) == min(end, sizeB) {
+				continue
+			}
+			if level == 0 || start >= min(sizeA, sizeB) {
+				ranges = append(ranges, LeafRange{Start: start, End: end})
+				continue
+			}
+
+			next = append(next, 2*index)
+			if (2*index+1)<<(level-1) < size {
+				next = append(next, 2*index+1)
+			}
+		}
+		frontier = next
+	}
+
+	return mergeRanges(ranges), nil
+}
+
+// fetchExisting requests the hashes of the nodes in indices that exist on the
+// given level of a tree with size leaves.
+func fetchExisting(source NodeSource, size, level int, indices []int) (map[int][32]byte, error) {
+	sizes := levelSizes(size)
+	if level >= len(sizes) {
+		return nil, nil
+	}
+
+	var existing []int
+	for _, index := range indices {
+		if index < sizes[level] {
+			existing = append(existing, index)
+		}
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	hashes, err := source.NodeHashes(level, existing)
+	if err != nil {
+		return nil, err
+	}
+	if len(hashes) != len(existing) {
+		return nil, fmt.Errorf("requested %d hashes on level %d, got %d", len(existing), level, len(hashes))
+	}
+
+	byIndex := make(map[int][32]byte, len(existing))
+	for i, index := range existing {
+		byIndex[index] = hashes[i]
+	}
+	return byIndex, nil
+}
+
+// mergeRanges sorts ranges and joins those that touch
+func mergeRanges(ranges []LeafRange) []LeafRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	var merged []LeafRange
+	for _, r := range ranges {
+		if len(merged) > 0 && merged[len(merged)-1].End >= r.Start {
+			merged[len(merged)-1].End = max(merged[len(merged)-1].End, r.End)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
@@ -0,0 +1,95 @@
+package merkle
+
+import (
+	"reflect"
+	"testing"
+)
+
+// countingSource wraps a NodeSource and counts the hashes requested from it
+type countingSource struct {
+	NodeSource
+	requested int
+}
+
+func (c *countingSource) NodeHashes(level int, indices []int) ([][32]byte, error) {
+	c.requested += len(indices)
+	return c.NodeSource.NodeHashes(level, indices)
+}
+
+func TestNodeHash(t *testing.T) {
+	files := testFiles(5)
+	tree := BuildMerkleTree(files)
+	flat := BuildFlatTree(files)
+
+	for level, hashes := range flat.Levels {
+		for index, want := range hashes {
+			got, err := tree.NodeHash(level, index)
+			if err != nil {
+				t.Fatalf("NodeHash(%d, %d) returned an error: %v", level, index, err)
+			}
+			if got != want {
+				t.Errorf("NodeHash(%d, %d) mismatch", level, index)
+			}
+		}
+	}
+
+	if _, err := tree.NodeHash(0, 5); err == nil {
+		t.Error("NodeHash should return an error for a node past the last leaf")
+	}
+	if _, err := tree.NodeHash(4, 0); err == nil {
+		t.Error("NodeHash should return an error for a level above the root")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := testFiles(16)
+	changed := append([]File{}, base...)
+	changed[3] = File{Data: "changed"}
+	changed[4] = File{Data: "changed"}
+	changed[12] = File{Data: "changed"}
+
+	tests := []struct {
+		name string
+		a, b []File
+		want []LeafRange
+	}{
+		{"identical", base, base, nil},
+		{"changed leaves", base, changed, []LeafRange{{3, 5}, {12, 13}}},
+		{"appended leaves", base[:10], base, []LeafRange{{10, 16}}},
+		{"removed leaves", base, base[:13], []LeafRange{{13, 16}}},
+		{"empty", nil, base[:3], []LeafRange{{0, 3}}},
+		// A duplicated last leaf hashes like an explicit pair of equal leaves
+		{"duplicated last leaf", []File{{"x"}}, []File{{"x"}, {"x"}}, []LeafRange{{1, 2}}},
+	}
+
+	for _, tc := range tests {
+		got, err := Diff(BuildMerkleTree(tc.a), BuildMerkleTree(tc.b))
+		if err != nil {
+			t.Fatalf("%s: Diff returned an error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: Diff = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDiffSkipsEqualSubtrees(t *testing.T) {
+	files := testFiles(1024)
+	changed := append([]File{}, files...)
+	changed[700] = File{Data: "changed"}
+
+	a := &countingSource{NodeSource: BuildMerkleTree(files)}
+	b := &countingSource{NodeSource: BuildMerkleTree(changed)}
+	got, err := Diff(a, b)
+	if err != nil {
+		t.Fatalf("Diff returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []LeafRange{{700, 701}}) {
+		t.Errorf("Diff = %v, want [{700 701}]", got)
+	}
+
+	// One path down an 11-level tree touches at most two nodes per level
+	if a.requested > 2*11 {
+		t.Errorf("Diff requested %d hashes, expected at most %d", a.requested, 2*11)
+	}
+}
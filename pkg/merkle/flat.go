@@ -0,0 +1,83 @@
+package merkle
+
+import "fmt"
+
+// FlatTree is a Merkle tree stored as one flat slice of hashes per level, with
+// siblings and parents found by index arithmetic instead of pointers. It holds
+// the same hashes as a MerkleTree built from the same files at a fraction of
+// the memory and without per-node allocations.
+type FlatTree struct {
+	// Levels[0] holds the leaf hashes and the last level holds the root
+	Levels [][][32]byte
+}
+
+// BuildFlatTree constructs a flat Merkle tree from the given files
+func BuildFlatTree(files []File) *FlatTree {
+	if len(files) == 0 {
+		return &FlatTree{}
+	}
+
+	leaves := make([][32]byte, len(files))
+	for i, file := range files {
+		leaves[i] = CreateHash([]byte(file.Data))
+	}
+
+	levels := [][][32]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][32]byte, (len(level)+1)/2)
+		for i := range next {
+			left := level[2*i]
+			right := left // Duplicate last node if number of nodes is odd
+			if 2*i+1 < len(level) {
+				right = level[2*i+1]
+			}
+			next[i] = HashPair(left[:], right[:])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return &FlatTree{Levels: levels}
+}
+
+// Size returns the number of leaves in the tree
+func (t *FlatTree) Size() int {
+	if len(t.Levels) == 0 {
+		return 0
+	}
+	return len(t.Levels[0])
+}
+
+// RootHash returns the root hash, or the zero hash for an empty tree
+func (t *FlatTree) RootHash() [32]byte {
+	if len(t.Levels) == 0 {
+		return [32]byte{}
+	}
+	return t.Levels[len(t.Levels)-1][0]
+}
+
+// GenerateProof generates a Merkle proof for the given file index in the same
+// format as MerkleTree.GenerateProof.
+func (t *FlatTree) GenerateProof(index int) ([][32]byte, []bool, error) {
+	if index < 0 || index >= t.Size() {
+		return nil, nil, fmt.Errorf("index out of range")
+	}
+
+	depth := len(t.Levels) - 1
+	proof := make([][32]byte, depth)
+	directions := make([]bool, depth)
+
+	// Fill from the end so the proof runs from the root down, like MerkleTree
+	for level := 0; level < depth; level++ {
+		nodes := t.Levels[level]
+		sibling := index ^ 1
+		if sibling >= len(nodes) {
+			sibling = index
+		}
+		proof[depth-1-level] = nodes[sibling]
+		directions[depth-1-level] = index%2 == 0
+		index /= 2
+	}
+
+	return proof, directions, nil
+}
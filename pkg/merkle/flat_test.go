@@ -0,0 +1,173 @@
+package merkle
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// sameProof compares two proofs, treating nil and empty slices as equal
+func sameProof(proof, want [][32]byte, directions, wantDirections []bool) bool {
+	if len(proof) == 0 && len(want) == 0 {
+		return len(directions) == 0 && len(wantDirections) == 0
+	}
+	return reflect.DeepEqual(proof, want) && reflect.DeepEqual(directions, wantDirections)
+}
+
+func TestFlatTreeMatchesBuildMerkleTree(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		files := testFiles(n)
+		tree := BuildMerkleTree(files)
+		flat := BuildFlatTree(files)
+
+		if flat.RootHash() != tree.Root.Hash {
+			t.Errorf("n=%d: root mismatch", n)
+		}
+
+		for i := 0; i < n; i++ {
+			wantProof, wantDirections, _ := tree.GenerateProof(i)
+			proof, directions, err := flat.GenerateProof(i)
+			if err != nil {
+				t.Fatalf("n=%d i=%d: GenerateProof returned an error: %v", n, i, err)
+			}
+			if !sameProof(proof, wantProof, directions, wantDirections) {
+				t.Errorf("n=%d i=%d: proof mismatch", n, i)
+			}
+		}
+	}
+}
+
+func TestFlatTreeEmptyInput(t *testing.T) {
+	flat := BuildFlatTree(nil)
+
+	if flat.Size() != 0 || flat.RootHash() != [32]byte{} {
+		t.Error("BuildFlatTree with empty input should return an empty tree")
+	}
+	if _, _, err := flat.GenerateProof(0); err == nil {
+		t.Error("GenerateProof should return an error on an empty tree")
+	}
+}
+
+func BenchmarkBuildMerkleTree(b *testing.B) {
+	files := testFiles(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildMerkleTree(files)
+	}
+}
+
+func BenchmarkBuildFlatTree(b *testing.B) {
+	files := testFiles(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildFlatTree(files)
+	}
+}
+
+func BenchmarkGenerateProofMerkleTree(b *testing.B) {
+	tree := BuildMerkleTree(testFiles(100000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = tree.GenerateProof(i % 100000)
+	}
+}
+
+func BenchmarkGenerateProofFlatTree(b *testing.B) {
+	tree := BuildFlatTree(testFiles(100000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = tree.GenerateProof(i % 100000)
+	}
+}
+
+// retainedBytes returns how much heap is still in use after a garbage
+// collection while the result of build is kept alive. Unlike ReportAllocs,
+// which counts every allocation made along the way, this is the memory a
+// tree actually holds on to.
+func retainedBytes(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	kept := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(kept)
+	if after.HeapAlloc < before.HeapAlloc {
+		return 0
+	}
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+func BenchmarkRetainedHeap(b *testing.B) {
+	files := testFiles(100000)
+	builds := map[string]func() any{
+		"pointer": func() any { return BuildMerkleTree(files) },
+		"flat":    func() any { return BuildFlatTree(files) },
+	}
+	for name, build := range builds {
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				retained = retainedBytes(build)
+			}
+			b.ReportMetric(float64(retained), "retained-B")
+			b.ReportMetric(float64(retained)/float64(len(files)), "retained-B/leaf")
+		})
+	}
+}
+
+// BenchmarkRetainedVersions keeps 100 versions of a tree, each changing one
+// leaf, either as persistent trees sharing unchanged subtrees or as separate
+// rebuilt trees
+func BenchmarkRetainedVersions(b *testing.B) {
+	const versions = 100
+	files := testFiles(10000)
+	changed := make([]File, versions)
+	for i := range changed {
+		changed[i] = File{Data: fmt.Sprintf("changed%d", i)}
+	}
+
+	builds := map[string]func() any{
+		"persistent": func() any {
+			trees := []*PersistentTree{NewPersistentTree(files)}
+			for i := 1; i < versions; i++ {
+				next, _ := trees[i-1].Update(i*97%len(files), changed[i])
+				trees = append(trees, next)
+			}
+			return trees
+		},
+		"rebuild-pointer": func() any {
+			current := append([]File{}, files...)
+			var trees []*MerkleTree
+			for i := 0; i < versions; i++ {
+				current[i*97%len(files)] = changed[i]
+				trees = append(trees, BuildMerkleTree(current))
+			}
+			return trees
+		},
+		"rebuild-flat": func() any {
+			current := append([]File{}, files...)
+			var trees []*FlatTree
+			for i := 0; i < versions; i++ {
+				current[i*97%len(files)] = changed[i]
+				trees = append(trees, BuildFlatTree(current))
+			}
+			return trees
+		},
+	}
+	for name, build := range builds {
+		b.Run(name, func(b *testing.B) {
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				retained = retainedBytes(build)
+			}
+			b.ReportMetric(float64(retained), "retained-B")
+			b.ReportMetric(float64(retained)/versions, "retained-B/version")
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type record struct {
+	ID    uint32
+	Value string
+}
+
+func encodeRecord(r record) []byte {
+	return binary.BigEndian.AppendUint32(nil, r.ID)
+}
+
+func TestBuildTreeBytesMatchesFiles(t *testing.T) {
+	files := testFiles(5)
+	raw := make([][]byte, len(files))
+	for i, file := range files {
+		raw[i] = []byte(file.Data)
+	}
+
+	if BuildTree(raw, EncodeBytes).Root.Hash != BuildMerkleTree(files).Root.Hash {
+		t.Error("Tree over raw bytes should have the same root as the File tree")
+	}
+}
+
+func TestBuildTreeTypedLeaves(t *testing.T) {
+	records := []record{{1, "a"}, {2, "b"}, {3, "c"}}
+	tree := BuildTree(records, encodeRecord)
+
+	if len(tree.Leaves) != len(records) {
+		t.Errorf("Expected %d leaves, got %d", len(records), len(tree.Leaves))
+	}
+
+	// Only the ID is encoded, so changing the value must not change the leaf
+	leaf, err := tree.LeafHash(record{2, "changed"})
+	if err != nil {
+		t.Fatalf("LeafHash returned an error: %v", err)
+	}
+	if leaf != tree.Leaves[1].Hash {
+		t.Error("LeafHash should hash items with the tree's encoder")
+	}
+
+	proof, directions, err := tree.GenerateProof(2)
+	if err != nil {
+		t.Fatalf("GenerateProof returned an error: %v", err)
+	}
+	if len(proof) != 2 || len(directions) != 2 {
+		t.Errorf("Expected proof length 2, got %d", len(proof))
+	}
+}
+
+func TestLeafHashWithoutEncoder(t *testing.T) {
+	var tree MerkleTree
+	if _, err := tree.LeafHash(File{Data: "file1"}); err == nil {
+		t.Error("LeafHash should return an error for a tree without an encoder")
+	}
+}
@@ -0,0 +1,126 @@
+package merkle
+
+import "fmt"
+
+// KaryTree is a Merkle tree in which every internal node hashes up to Arity
+// children, trading wider proof steps for a shallower tree. A short last group
+// at any level is padded by repeating its last node, which generalises the
+// binary tree's duplication so that an arity of 2 gives the same root as
+// BuildMerkleTree.
+type KaryTree struct {
+	Arity int
+	// Levels[0] holds the leaf hashes and the last level holds the root
+	Levels [][][32]byte
+}
+
+// KaryProofStep is one level of a k-ary inclusion proof
+type KaryProofStep struct {
+	// Position is the index of the proven node among its siblings
+	Position int `json:"position"`
+	// Siblings holds the other Arity-1 children of the parent, in order
+	Siblings [][32]byte `json:"siblings"`
+}
+
+// BuildKaryTree constructs a Merkle tree with the given fan-out from the files
+func BuildKaryTree(files []File, arity int) (*KaryTree, error) {
+	if arity < 2 {
+		return nil, fmt.Errorf("arity must be at least 2, got %d", arity)
+	}
+	if len(files) == 0 {
+		return &KaryTree{Arity: arity}, nil
+	}
+
+	leaves := make([][32]byte, len(files))
+	for i, file := range files {
+		leaves[i] = CreateHash([]byte(file.Data))
+	}
+
+	levels := [][][32]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		next := make([][32]byte, (len(level)+arity-1)/arity)
+		for i := range next {
+			group := make([]byte, 0, arity*32)
+			for j := 0; j < arity; j++ {
+				child := level[min(i*arity+j, len(level)-1)]
+				group = append(group, child[:]...)
+			}
+			next[i] = CreateHash(group)
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return &KaryTree{Arity: arity, Levels: levels}, nil
+}
+
+// Size returns the number of leaves in the tree
+func (t *KaryTree) Size() int {
+	if len(t.Levels) == 0 {
+		return 0
+	}
+	return len(t.Levels[0])
+}
+
+// Depth returns the number of levels above the leaves
+func (t *KaryTree) Depth() int {
+	return max(len(t.Levels)-1, 0)
+}
+
+// RootHash returns the root hash, or the zero hash for an empty tree
+func (t *KaryTree) RootHash() [32]byte {
+	if len(t.Levels) == 0 {
+		return [32]byte{}
+	}
+	return t.Levels[len(t.Levels)-1][0]
+}
+
+// GenerateProof generates an inclusion proof, ordered from the leaf up, for the given file index
+func (t *KaryTree) GenerateProof(index int) ([]KaryProofStep, error) {
+	if index < 0 || index >= t.Size() {
+		return nil, fmt.Errorf("index out of range")
+	}
+
+	var proof []KaryProofStep
+	for _, level := range t.Levels[:len(t.Levels)-1] {
+		first := index / t.Arity * t.Arity
+		step := KaryProofStep{Position: index - first}
+		for j := 0; j < t.Arity; j++ {
+			if first+j != index {
+				step.Siblings = append(step.Siblings, level[min(first+j, len(level)-1)])
+			}
+		}
+		proof = append(proof, step)
+		index /= t.Arity
+	}
+	return proof, nil
+}
+
+// VerifyKaryProof checks that leafHash is included under root according to proof
+func VerifyKaryProof(leafHash [32]byte, proof []KaryProofStep, root [32]byte) bool {
+	hash := leafHash
+	for _, step := range proof {
+		if step.Position < 0 || step.Position > len(step.Siblings) {
+			return false
+		}
+
+		group := make([]byte, 0, (len(step.Siblings)+1)*32)
+		for _, sibling := range step.Siblings[:step.Position] {
+			group = append(group, sibling[:]...)
+		}
+		group = append(group, hash[:]...)
+		for _, sibling := range step.Siblings[step.Position:] {
+			group = append(group, sibling[:]...)
+		}
+		hash = CreateHash(group)
+	}
+	return hash == root
+}
+
+// KaryProofSize returns the number of hash bytes carried by a proof
+func KaryProofSize(proof []KaryProofStep) int {
+	size := 0
+	for _, step := range proof {
+		size += len(step.Siblings) * 32
+	}
+	return size
+}
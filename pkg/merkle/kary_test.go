@@ -0,0 +1,78 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKaryTreeProofs(t *testing.T) {
+	for _, arity := range []int{2, 3, 4, 8, 16} {
+		for _, n := range []int{1, 2, 5, 16, 17, 100} {
+			files := testFiles(n)
+			tree, err := BuildKaryTree(files, arity)
+			if err != nil {
+				t.Fatalf("arity=%d n=%d: BuildKaryTree returned an error: %v", arity, n, err)
+			}
+
+			for i, file := range files {
+				proof, err := tree.GenerateProof(i)
+				if err != nil {
+					t.Fatalf("arity=%d n=%d i=%d: GenerateProof returned an error: %v", arity, n, i, err)
+				}
+				if len(proof) != tree.Depth() {
+					t.Errorf("arity=%d n=%d i=%d: expected %d steps, got %d", arity, n, i, tree.Depth(), len(proof))
+				}
+				if !VerifyKaryProof(CreateHash([]byte(file.Data)), proof, tree.RootHash()) {
+					t.Errorf("arity=%d n=%d i=%d: proof did not verify", arity, n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestKaryTreeBinaryMatchesBuildMerkleTree(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		files := testFiles(n)
+		tree, _ := BuildKaryTree(files, 2)
+		if tree.RootHash() != BuildMerkleTree(files).Root.Hash {
+			t.Errorf("n=%d: arity 2 root doesn't match BuildMerkleTree", n)
+		}
+	}
+}
+
+func TestKaryTreeDepth(t *testing.T) {
+	tree, _ := BuildKaryTree(testFiles(4096), 16)
+	if tree.Depth() != 3 {
+		t.Errorf("Expected depth 3 for 4096 leaves at arity 16, got %d", tree.Depth())
+	}
+}
+
+func TestKaryTreeInvalid(t *testing.T) {
+	if _, err := BuildKaryTree(testFiles(2), 1); err == nil {
+		t.Error("BuildKaryTree should return an error for arity below 2")
+	}
+
+	tree, _ := BuildKaryTree(testFiles(4), 4)
+	proof, _ := tree.GenerateProof(0)
+	proof[0].Position = 5
+	if VerifyKaryProof(CreateHash([]byte("file1")), proof, tree.RootHash()) {
+		t.Error("VerifyKaryProof should reject a step with an invalid position")
+	}
+}
+
+// BenchmarkKaryProof reports proof size and depth for each arity over the same leaves
+func BenchmarkKaryProof(b *testing.B) {
+	files := testFiles(65536)
+	for _, arity := range []int{2, 4, 8, 16} {
+		tree, _ := BuildKaryTree(files, arity)
+		b.Run(fmt.Sprintf("arity=%d", arity), func(b *testing.B) {
+			var proof []KaryProofStep
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				proof, _ = tree.GenerateProof(i % len(files))
+			}
+			b.ReportMetric(float64(KaryProofSize(proof)), "proof-bytes")
+			b.ReportMetric(float64(tree.Depth()), "depth")
+		})
+	}
+}
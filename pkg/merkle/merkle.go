@@ -18,31 +18,76 @@ type Node struct {
 	Hash   [32]byte
 }
 
-// MerkleTree represents the entire Merkle tree
-type MerkleTree struct {
+// LeafEncoder returns the bytes that are hashed to form the leaf for an item
+type LeafEncoder[T any] func(item T) []byte
+
+// Tree represents a Merkle tree over leaves of type T
+type Tree[T any] struct {
 	Root   *Node
 	Leaves []*Node
+	encode LeafEncoder[T]
 }
 
+// MerkleTree represents the entire Merkle tree over files
+type MerkleTree = Tree[File]
+
 // CreateHash computes the SHA-256 hash of input data
 func CreateHash(data []byte) [32]byte {
 	return sha256.Sum256(data)
 }
 
-// BuildMerkleTree constructs a Merkle tree from the given files
-func BuildMerkleTree(files []File) *MerkleTree {
-	if len(files) == 0 {
-		return &MerkleTree{}
+// EncodeFile is the LeafEncoder for File leaves
+func EncodeFile(file File) []byte {
+	return []byte(file.Data)
+}
+
+// EncodeBytes is the LeafEncoder for raw byte leaves. It hashes the slice in place without copying.
+func EncodeBytes(data []byte) []byte {
+	return data
+}
+
+// BuildTree constructs a Merkle tree from the given items, hashing the bytes encode returns for each
+func BuildTree[T any](items []T, encode LeafEncoder[T]) *Tree[T] {
+	if len(items) == 0 {
+		return &Tree[T]{encode: encode}
 	}
 
-	var leaves []*Node
-	for _, file := range files {
-		leaf := &Node{Hash: CreateHash([]byte(file.Data))}
+	leaves := make([]*Node, 0, len(items))
+	for _, item := range items {
+		leaf := &Node{Hash: CreateHash(encode(item))}
 		leaves = append(leaves, leaf)
 	}
 
 	root := buildTree(leaves)
-	return &MerkleTree{Root: root, Leaves: leaves}
+	return &Tree[T]{Root: root, Leaves: leaves, encode: encode}
+}
+
+// BuildTreeFromHashes constructs a Merkle tree from precomputed leaf hashes
+func BuildTreeFromHashes[T any](hashes [][32]byte, encode LeafEncoder[T]) *Tree[T] {
+	if len(hashes) == 0 {
+		return &Tree[T]{encode: encode}
+	}
+
+	leaves := make([]*Node, len(hashes))
+	for i, hash := range hashes {
+		leaves[i] = &Node{Hash: hash}
+	}
+
+	root := buildTree(leaves)
+	return &Tree[T]{Root: root, Leaves: leaves, encode: encode}
+}
+
+// BuildMerkleTree constructs a Merkle tree from the given files
+func BuildMerkleTree(files []File) *MerkleTree {
+	return BuildTree(files, EncodeFile)
+}
+
+// LeafHash hashes an item the way the tree hashes its leaves
+func (t *Tree[T]) LeafHash(item T) ([32]byte, error) {
+	if t.encode == nil {
+		return [32]byte{}, fmt.Errorf("tree has no leaf encoder")
+	}
+	return CreateHash(t.encode(item)), nil
 }
 
 // buildTree builds the tree from the leaves up to the root
@@ -90,7 +135,7 @@ func HashPair(left, right []byte) [32]byte {
 }
 
 // GenerateProof generates a Merkle proof for the given file index.
-func (t *MerkleTree) GenerateProof(index int) ([][32]byte, []bool, error) {
+func (t *Tree[T]) GenerateProof(index int) ([][32]byte, []bool, error) {
 	if index < 0 || index >= len(t.Leaves) {
 		return nil, nil, fmt.Errorf("index out of range")
 	}
@@ -0,0 +1,271 @@
+package merkle
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// MountainRange is a Merkle Mountain Range: an append-only list of perfect
+// binary trees ("peaks") of strictly decreasing height. Appending a leaf only
+// merges peaks of equal height, so existing nodes never change and an
+// inclusion proof stays valid against its peak for the lifetime of the range.
+// The root is the bagging of all peaks.
+type MountainRange struct {
+	// levels[h] holds the hashes of every complete subtree of height h, left to right
+	levels [][][32]byte
+}
+
+// MMRProof is an inclusion proof for a leaf in a MountainRange of Size leaves
+type MMRProof struct {
+	LeafIndex int
+	Size      int
+	// Path holds the siblings from the leaf up to the root of its peak
+	Path [][32]byte
+	// Peaks holds every peak of the range, highest first
+	Peaks [][32]byte
+}
+
+// NewMountainRange builds a mountain range from the given files
+func NewMountainRange(files []File) *MountainRange {
+	m := &MountainRange{}
+	for _, file := range files {
+		m.Append(file)
+	}
+	return m
+}
+
+// Size returns the number of leaves in the range
+func (m *MountainRange) Size() int {
+	if len(m.levels) == 0 {
+		return 0
+	}
+	return len(m.levels[0])
+}
+
+// LeafHashes returns the hashes of all leaves in order
+func (m *MountainRange) LeafHashes() [][32]byte {
+	if len(m.levels) == 0 {
+		return nil
+	}
+	return m.levels[0]
+}
+
+// Append adds the file as the next leaf and merges any peaks of equal height
+func (m *MountainRange) Append(file File) {
+	m.AppendHash(CreateHash([]byte(file.Data)))
+}
+
+// AppendHash adds a precomputed leaf hash as the next leaf
+func (m *MountainRange) AppendHash(hash [32]byte) {
+	for h := 0; ; h++ {
+		if h == len(m.levels) {
+			m.levels = append(m.levels, nil)
+		}
+		m.levels[h] = append(m.levels[h], hash)
+		count := len(m.levels[h])
+		if count%2 == 1 {
+			return
+		}
+		hash = HashPair(m.levels[h][count-2][:], hash[:])
+	}
+}
+
+// Peaks returns the peak hashes of the range when it held size leaves, highest first
+func (m *MountainRange) Peaks(size int) ([][32]byte, error) {
+	if size < 0 || size > m.Size() {
+		return nil, fmt.Errorf("size out of range")
+	}
+
+	var peaks [][32]byte
+	for h := len(m.levels) - 1; h >= 0; h-- {
+		if size>>h&1 == 1 {
+			peaks = append(peaks, m.levels[h][size>>h-1])
+		}
+	}
+	return peaks, nil
+}
+
+// BagPeaks folds the peaks, highest first, into a single root hash
+func BagPeaks(peaks [][32]byte) [32]byte {
+	if len(peaks) == 0 {
+		return [32]byte{}
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = HashPair(peaks[i][:], root[:])
+	}
+	return root
+}
+
+// Root returns the bagged root of the current range
+func (m *MountainRange) Root() [32]byte {
+	root, _ := m.RootAt(m.Size())
+	return root
+}
+
+// RootAt returns the bagged root of the range as it was when it held size leaves
+func (m *MountainRange) RootAt(size int) ([32]byte, error) {
+	peaks, err := m.Peaks(size)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return BagPeaks(peaks), nil
+}
+
+// GenerateProof generates an inclusion proof for the leaf at index
+func (m *MountainRange) GenerateProof(index int) (*MMRProof, error) {
+	return m.GenerateProofAt(m.Size(), index)
+}
+
+// GenerateProofAt generates an inclusion proof for the leaf at index against
+// the range as it was when it held size leaves.
+func (m *MountainRange) GenerateProofAt(size, index int) (*MMRProof, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("index out of range")
+	}
+	peaks, err := m.Peaks(size)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &MMRProof{LeafIndex: index, Size: size, Peaks: peaks}
+	m.extendPath(proof, peakHeight(size, index))
+	return proof, nil
+}
+
+// UpdateProof brings a proof generated against an earlier size up to date
+// with the current range. Only the siblings of peaks that have merged since
+// are added; the existing path is reused as is.
+func (m *MountainRange) UpdateProof(proof *MMRProof) (*MMRProof, error) {
+	if proof.Size > m.Size() || proof.LeafIndex < 0 || proof.LeafIndex >= proof.Size {
+		return nil, fmt.Errorf("proof does not belong to this range")
+	}
+	peaks, err := m.Peaks(m.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	updated := &MMRProof{
+		LeafIndex: proof.LeafIndex,
+		Size:      m.Size(),
+		Path:      append([][32]byte{}, proof.Path...),
+		Peaks:     peaks,
+	}
+	m.extendPath(updated, peakHeight(updated.Size, updated.LeafIndex))
+	return updated, nil
+}
+
+// extendPath appends siblings to proof.Path until it reaches height
+func (m *MountainRange) extendPath(proof *MMRProof, height int) {
+	for h := len(proof.Path); h < height; h++ {
+		proof.Path = append(proof.Path, m.levels[h][proof.LeafIndex>>h^1])
+	}
+}
+
+// peakHeight returns the height of the peak containing the leaf at index in a range of size leaves
+func peakHeight(size, index int) int {
+	start := 0
+	for h := bits.Len(uint(size)) - 1; h >= 0; h-- {
+		if size>>h&1 == 0 {
+			continue
+		}
+		if index < start+1<<h {
+			return h
+		}
+		start += 1 << h
+	}
+	return 0
+}
+
+// peakPosition returns the position among the peaks, highest first, of the peak containing the leaf at index
+func peakPosition(size, index int) int {
+	position, start := 0, 0
+	for h := bits.Len(uint(size)) - 1; h >= 0; h-- {
+		if size>>h&1 == 0 {
+			continue
+		}
+		if index < start+1<<h {
+			return position
+		}
+		start += 1 << h
+		position++
+	}
+	return position
+}
+
+// VerifyMMRProof checks that leafHash is included under root according to proof
+func VerifyMMRProof(leafHash [32]byte, proof *MMRProof, root [32]byte) bool {
+	if proof == nil || proof.LeafIndex < 0 || proof.LeafIndex >= proof.Size {
+		return false
+	}
+	position := peakPosition(proof.Size, proof.LeafIndex)
+	if len(proof.Path) != peakHeight(proof.Size, proof.LeafIndex) ||
+		len(proof.Peaks) != bits.OnesCount(uint(proof.Size)) {
+		return false
+	}
+
+	hash := leafHash
+	for h, sibling := range proof.Path {
+		if proof.LeafIndex>>h&1 == 0 {
+			hash = HashPair(hash[:], sibling[:])
+		} else {
+			hash = HashPair(sibling[:], hash[:])
+		}
+	}
+
+	return hash == proof.Peaks[position] && BagPeaks(proof.Peaks) == root
+}
+
+// VerifyFlatMMRProofAt checks a proof returned by MMRProof.Flatten like
+// VerifyProofAt: the directions must be those of the leaf at index in a
+// mountain range of size leaves
+func VerifyFlatMMRProofAt(leafHash [32]byte, index, size int, proof [][32]byte, directions []bool, root [32]byte) bool {
+	if index < 0 || index >= size || len(directions) != len(proof) {
+		return false
+	}
+	shape := &MMRProof{
+		LeafIndex: index,
+		Size:      size,
+		Path:      make([][32]byte, peakHeight(size, index)),
+		Peaks:     make([][32]byte, bits.OnesCount(uint(size))),
+	}
+	_, want := shape.Flatten()
+	if len(want) != len(directions) {
+		return false
+	}
+	for i := range want {
+		if directions[i] != want[i] {
+			return false
+		}
+	}
+	return VerifyProof(leafHash, proof, directions, root)
+}
+
+// Flatten converts the proof into the root-first proof and direction lists
+// returned by MerkleTree.GenerateProof, folding peak bagging into the path so
+// the same verifier works for both tree structures.
+func (p *MMRProof) Flatten() ([][32]byte, []bool) {
+	position := peakPosition(p.Size, p.LeafIndex)
+
+	var proof [][32]byte
+	var directions []bool
+	for h, sibling := range p.Path {
+		proof = append(proof, sibling)
+		directions = append(directions, p.LeafIndex>>h&1 == 0)
+	}
+	if position < len(p.Peaks)-1 {
+		proof = append(proof, BagPeaks(p.Peaks[position+1:]))
+		directions = append(directions, true)
+	}
+	for i := position - 1; i >= 0; i-- {
+		proof = append(proof, p.Peaks[i])
+		directions = append(directions, false)
+	}
+
+	// Reverse so the proof runs from the root down, like MerkleTree
+	for i, j := 0, len(proof)-1; i < j; i, j = i+1, j-1 {
+		proof[i], proof[j] = proof[j], proof[i]
+		directions[i], directions[j] = directions[j], directions[i]
+	}
+	return proof, directions
+}
@@ -0,0 +1,127 @@
+package merkle
+
+import "testing"
+
+func TestMountainRangeProofs(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		files := testFiles(n)
+		m := NewMountainRange(files)
+		root := m.Root()
+
+		for i, file := range files {
+			proof, err := m.GenerateProof(i)
+			if err != nil {
+				t.Fatalf("n=%d i=%d: GenerateProof returned an error: %v", n, i, err)
+			}
+			if !VerifyMMRProof(CreateHash([]byte(file.Data)), proof, root) {
+				t.Errorf("n=%d i=%d: proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestMountainRangeMatchesBinaryTreeAtPowersOfTwo(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 8, 16} {
+		files := testFiles(n)
+		if NewMountainRange(files).Root() != BuildMerkleTree(files).Root.Hash {
+			t.Errorf("n=%d: a single-peak range should have the same root as the binary tree", n)
+		}
+	}
+}
+
+func TestMountainRangePeaks(t *testing.T) {
+	m := NewMountainRange(testFiles(11)) // 11 = 8 + 2 + 1
+
+	peaks, err := m.Peaks(11)
+	if err != nil {
+		t.Fatalf("Peaks returned an error: %v", err)
+	}
+	if len(peaks) != 3 {
+		t.Errorf("Expected 3 peaks, got %d", len(peaks))
+	}
+
+	// Peaks of an earlier size are still present unchanged in the grown range
+	early := NewMountainRange(testFiles(6))
+	earlyRoot := early.Root()
+	root, err := m.RootAt(6)
+	if err != nil || root != earlyRoot {
+		t.Error("RootAt should reproduce the root of an earlier size")
+	}
+}
+
+func TestMountainRangeUpdateProof(t *testing.T) {
+	files := testFiles(13)
+	m := NewMountainRange(files[:5])
+	proof, err := m.GenerateProof(2)
+	if err != nil {
+		t.Fatalf("GenerateProof returned an error: %v", err)
+	}
+	oldRoot := m.Root()
+
+	for _, file := range files[5:] {
+		m.Append(file)
+	}
+
+	leaf := CreateHash([]byte(files[2].Data))
+	if !VerifyMMRProof(leaf, proof, oldRoot) {
+		t.Error("Old proof should still verify against the old root")
+	}
+
+	updated, err := m.UpdateProof(proof)
+	if err != nil {
+		t.Fatalf("UpdateProof returned an error: %v", err)
+	}
+	if !VerifyMMRProof(leaf, updated, m.Root()) {
+		t.Error("Updated proof did not verify against the new root")
+	}
+	for i := range proof.Path {
+		if updated.Path[i] != proof.Path[i] {
+			t.Error("UpdateProof should keep the existing path")
+		}
+	}
+}
+
+func TestMMRProofFlatten(t *testing.T) {
+	files := testFiles(7)
+	m := NewMountainRange(files)
+
+	for i, file := range files {
+		proof, _ := m.GenerateProof(i)
+		hashes, directions := proof.Flatten()
+
+		// Walk the flattened proof from the leaf up, as a MerkleTree proof would be
+		hash := CreateHash([]byte(file.Data))
+		for j := len(hashes) - 1; j >= 0; j-- {
+			if directions[j] {
+				hash = HashPair(hash[:], hashes[j][:])
+			} else {
+				hash = HashPair(hashes[j][:], hash[:])
+			}
+		}
+		if hash != m.Root() {
+			t.Errorf("i=%d: flattened proof did not reach the root", i)
+		}
+
+		leaf := CreateHash([]byte(file.Data))
+		if !VerifyFlatMMRProofAt(leaf, i, len(files), hashes, directions, m.Root()) {
+			t.Errorf("i=%d: VerifyFlatMMRProofAt rejected a valid proof", i)
+		}
+		if VerifyFlatMMRProofAt(leaf, (i+1)%len(files), len(files), hashes, directions, m.Root()) {
+			t.Errorf("i=%d: VerifyFlatMMRProofAt accepted the proof for another index", i)
+		}
+	}
+}
+
+func TestMountainRangeOutOfRange(t *testing.T) {
+	m := NewMountainRange(testFiles(3))
+
+	if _, err := m.GenerateProof(3); err == nil {
+		t.Error("GenerateProof should return an error for out of range index")
+	}
+	if _, err := m.RootAt(4); err == nil {
+		t.Error("RootAt should return an error for a size larger than the range")
+	}
+	if VerifyMMRProof([32]byte{}, &MMRProof{LeafIndex: 1, Size: 1}, m.Root()) {
+		t.Error("VerifyMMRProof should reject a malformed proof")
+	}
+}
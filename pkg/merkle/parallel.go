@@ -0,0 +1,96 @@
+package merkle
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// parallelChunkSize is the number of nodes a worker hashes before checking for cancellation
+const parallelChunkSize = 1024
+
+// BuildMerkleTreeParallel constructs the same tree as BuildMerkleTree, hashing
+// leaves and each level across at most workers goroutines. A workers value
+// below one uses runtime.NumCPU. It returns ctx.Err() if ctx is cancelled
+// before the tree is complete.
+func BuildMerkleTreeParallel(ctx context.Context, files []File, workers int) (*MerkleTree, error) {
+	if len(files) == 0 {
+		return &MerkleTree{encode: EncodeFile}, nil
+	}
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	leaves := make([]*Node, len(files))
+	err := parallelRange(ctx, len(files), workers, func(i int) {
+		leaves[i] = &Node{Hash: CreateHash([]byte(files[i].Data))}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		nodes := level
+		next := make([]*Node, (len(nodes)+1)/2)
+		err := parallelRange(ctx, len(next), workers, func(i int) {
+			left, right := nodes[2*i], (*Node)(nil)
+			if 2*i+1 < len(nodes) {
+				right = nodes[2*i+1]
+			} else {
+				right = &Node{Hash: left.Hash} // Duplicate last node if number of nodes is odd
+			}
+
+			parent := &Node{
+				Left:  left,
+				Right: right,
+				Hash:  HashPair(left.Hash[:], right.Hash[:]),
+			}
+			left.Parent, right.Parent = parent, parent
+			next[i] = parent
+		})
+		if err != nil {
+			return nil, err
+		}
+		level = next
+	}
+
+	return &MerkleTree{Root: level[0], Leaves: leaves, encode: EncodeFile}, nil
+}
+
+// parallelRange calls fn for every index in [0, n) using a bounded pool of workers
+// that pull chunks of indices until the range is exhausted or ctx is cancelled.
+func parallelRange(ctx context.Context, n, workers int, fn func(i int)) error {
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := min(start+parallelChunkSize, n)
+				for i := start; i < end; i++ {
+					fn(i)
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for start := 0; start < n; start += parallelChunkSize {
+		select {
+		case chunks <- start:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
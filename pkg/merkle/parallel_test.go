@@ -0,0 +1,73 @@
+package merkle
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildMerkleTreeParallelMatchesSequential(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 1024, 1025, 5000} {
+		files := testFiles(n)
+		want := BuildMerkleTree(files)
+
+		for _, workers := range []int{0, 1, 4} {
+			tree, err := BuildMerkleTreeParallel(context.Background(), files, workers)
+			if err != nil {
+				t.Fatalf("n=%d workers=%d: unexpected error: %v", n, workers, err)
+			}
+			if tree.Root.Hash != want.Root.Hash {
+				t.Errorf("n=%d workers=%d: root mismatch", n, workers)
+			}
+			if len(tree.Leaves) != n {
+				t.Errorf("n=%d workers=%d: expected %d leaves, got %d", n, workers, n, len(tree.Leaves))
+			}
+		}
+	}
+}
+
+func TestBuildMerkleTreeParallelProofs(t *testing.T) {
+	files := testFiles(11)
+	want := BuildMerkleTree(files)
+	tree, err := BuildMerkleTreeParallel(context.Background(), files, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := range files {
+		wantProof, wantDirections, _ := want.GenerateProof(i)
+		proof, directions, err := tree.GenerateProof(i)
+		if err != nil {
+			t.Fatalf("i=%d: GenerateProof returned an error: %v", i, err)
+		}
+		if !sameProof(proof, wantProof, directions, wantDirections) {
+			t.Errorf("i=%d: proof mismatch", i)
+		}
+	}
+}
+
+func TestBuildMerkleTreeParallelCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := BuildMerkleTreeParallel(ctx, testFiles(10), 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBuildMerkleTreeParallelEmptyInput(t *testing.T) {
+	tree, err := BuildMerkleTreeParallel(context.Background(), nil, 2)
+	if err != nil || tree.Root != nil {
+		t.Error("BuildMerkleTreeParallel with empty input should return an empty tree")
+	}
+}
+
+func BenchmarkBuildMerkleTreeParallel(b *testing.B) {
+	files := testFiles(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = BuildMerkleTreeParallel(context.Background(), files, 0)
+	}
+}
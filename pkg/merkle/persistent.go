@@ -0,0 +1,197 @@
+package merkle
+
+import "fmt"
+
+// PersistentTree is an immutable Merkle tree. Append and Update return a new
+// tree that shares every unchanged subtree with the receiver, so each version
+// only costs O(log N) new nodes. Roots and proofs match BuildMerkleTree for the
+// same leaves.
+type PersistentTree struct {
+	root  *persistentNode
+	size  int
+	depth int
+}
+
+// persistentNode is a node of a PersistentTree. A nil child marks a subtree
+// that lies entirely past the last leaf.
+type persistentNode struct {
+	left  *persistentNode
+	right *persistentNode
+	hash  [32]byte
+}
+
+// NewPersistentTree builds a persistent tree from the given files
+func NewPersistentTree(files []File) *PersistentTree {
+	t := &PersistentTree{}
+	for _, file := range files {
+		t = t.Append(file)
+	}
+	return t
+}
+
+// Size returns the number of leaves in the tree
+func (t *PersistentTree) Size() int {
+	return t.size
+}
+
+// RootHash returns the root hash, or the zero hash for an empty tree
+func (t *PersistentTree) RootHash() [32]byte {
+	if t.root == nil {
+		return [32]byte{}
+	}
+	return t.root.hash
+}
+
+// Append returns a new tree with the file added as the last leaf
+func (t *PersistentTree) Append(file File) *PersistentTree {
+	return t.AppendHash(CreateHash([]byte(file.Data)))
+}
+
+// AppendHash returns a new tree with a precomputed leaf hash added as the last leaf
+func (t *PersistentTree) AppendHash(hash [32]byte) *PersistentTree {
+	leaf := &persistentNode{hash: hash}
+	if t.root == nil {
+		return &PersistentTree{root: leaf, size: 1}
+	}
+
+	root, depth := t.root, t.depth
+	if t.size == 1<<depth {
+		// The tree is full, so the old root becomes the left half of a new level
+		root = &persistentNode{left: root}
+		depth++
+	}
+
+	return &PersistentTree{
+		root:  setLeaf(root, depth, t.size, leaf),
+		size:  t.size + 1,
+		depth: depth,
+	}
+}
+
+// Update returns a new tree with the leaf at index replaced by file
+func (t *PersistentTree) Update(index int, file File) (*PersistentTree, error) {
+	if index < 0 || index >= t.size {
+		return nil, fmt.Errorf("index out of range")
+	}
+
+	leaf := &persistentNode{hash: CreateHash([]byte(file.Data))}
+	return &PersistentTree{
+		root:  setLeaf(t.root, t.depth, index, leaf),
+		size:  t.size,
+		depth: t.depth,
+	}, nil
+}
+
+// setLeaf copies the path from n down to the leaf at index and rehashes it on the way back up
+func setLeaf(n *persistentNode, depth, index int, leaf *persistentNode) *persistentNode {
+	if depth == 0 {
+		return leaf
+	}
+
+	copied := &persistentNode{}
+	if n != nil {
+		copied.left, copied.right = n.left, n.right
+	}
+	if index < 1<<(depth-1) {
+		copied.left = setLeaf(copied.left, depth-1, index, leaf)
+	} else {
+		copied.right = setLeaf(copied.right, depth-1, index-1<<(depth-1), leaf)
+	}
+	copied.hash = pairHash(copied.left, copied.right)
+	return copied
+}
+
+// pairHash hashes two children, duplicating the left one when the right is missing
+func pairHash(left, right *persistentNode) [32]byte {
+	if right == nil {
+		return HashPair(left.hash[:], left.hash[:])
+	}
+	return HashPair(left.hash[:], right.hash[:])
+}
+
+// LeafHashes returns the hashes of every leaf in order
+func (t *PersistentTree) LeafHashes() [][32]byte {
+	hashes := make([][32]byte, 0, t.size)
+	var walk func(n *persistentNode, depth int)
+	walk = func(n *persistentNode, depth int) {
+		if n == nil {
+			return
+		}
+		if depth == 0 {
+			hashes = append(hashes, n.hash)
+			return
+		}
+		walk(n.left, depth-1)
+		walk(n.right, depth-1)
+	}
+	walk(t.root, t.depth)
+	return hashes
+}
+
+// GenerateProof generates a Merkle proof for the given leaf index in the same
+// format as MerkleTree.GenerateProof.
+func (t *PersistentTree) GenerateProof(index int) ([][32]byte, []bool, error) {
+	if index < 0 || index >= t.size {
+		return nil, nil, fmt.Errorf("index out of range")
+	}
+
+	var proof [][32]byte
+	var directions []bool
+	n := t.root
+	for depth := t.depth; depth > 0; depth-- {
+		half := 1 << (depth - 1)
+		if index < half {
+			sibling := n.right
+			if sibling == nil {
+				sibling = n.left
+			}
+			proof = append(proof, sibling.hash)
+			directions = append(directions, true)
+			n = n.left
+		} else {
+			proof = append(proof, n.left.hash)
+			directions = append(directions, false)
+			n = n.right
+			index -= half
+		}
+	}
+
+	return proof, directions, nil
+}
+
+// TreeSize implements NodeSource
+func (t *PersistentTree) TreeSize() (int, error) {
+	return t.size, nil
+}
+
+// NodeHash returns the hash of the node at index on the given level, using
+// the same numbering as Tree.NodeHash.
+func (t *PersistentTree) NodeHash(level, index int) ([32]byte, error) {
+	sizes := levelSizes(t.size)
+	if level < 0 || level >= len(sizes) || index < 0 || index >= sizes[level] {
+		return [32]byte{}, fmt.Errorf("node %d on level %d out of range", index, level)
+	}
+
+	n := t.root
+	for l := t.depth; l > level; l-- {
+		if index>>(l-1-level)&1 == 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return n.hash, nil
+}
+
+// NodeHashes implements NodeSource
+func (t *PersistentTree) NodeHashes(level int, indices []int) ([][32]byte, error) {
+	hashes := make([][32]byte, len(indices))
+	for i, index := range indices {
+		hash, err := t.NodeHash(level, index)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
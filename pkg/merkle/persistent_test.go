@@ -0,0 +1,121 @@
+package merkle
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testFiles(n int) []File {
+	files := make([]File, n)
+	for i := range files {
+		files[i] = File{Data: fmt.Sprintf("file%d", i+1)}
+	}
+	return files
+}
+
+func TestPersistentTreeMatchesBuildMerkleTree(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		files := testFiles(n)
+		tree := BuildMerkleTree(files)
+		persistent := NewPersistentTree(files)
+
+		if persistent.Size() != n {
+			t.Errorf("n=%d: expected size %d, got %d", n, n, persistent.Size())
+		}
+		if persistent.RootHash() != tree.Root.Hash {
+			t.Errorf("n=%d: root mismatch", n)
+		}
+
+		for i := 0; i < n; i++ {
+			wantProof, wantDirections, _ := tree.GenerateProof(i)
+			proof, directions, err := persistent.GenerateProof(i)
+			if err != nil {
+				t.Fatalf("n=%d i=%d: GenerateProof returned an error: %v", n, i, err)
+			}
+			if !reflect.DeepEqual(proof, wantProof) || !reflect.DeepEqual(directions, wantDirections) {
+				t.Errorf("n=%d i=%d: proof mismatch", n, i)
+			}
+		}
+
+		leaves := persistent.LeafHashes()
+		if len(leaves) != n {
+			t.Fatalf("n=%d: expected %d leaf hashes, got %d", n, n, len(leaves))
+		}
+		for i, leaf := range tree.Leaves {
+			if leaves[i] != leaf.Hash {
+				t.Errorf("n=%d i=%d: leaf hash mismatch", n, i)
+			}
+		}
+	}
+}
+
+func TestPersistentTreeVersionsAreImmutable(t *testing.T) {
+	files := testFiles(5)
+	v1 := NewPersistentTree(files[:4])
+	rootV1 := v1.RootHash()
+
+	v2 := v1.Append(files[4])
+	if v1.RootHash() != rootV1 || v1.Size() != 4 {
+		t.Error("Append modified the original tree")
+	}
+	if v2.RootHash() != BuildMerkleTree(files).Root.Hash {
+		t.Error("Append produced an unexpected root")
+	}
+
+	v3, err := v2.Update(1, File{Data: "changed"})
+	if err != nil {
+		t.Fatalf("Update returned an error: %v", err)
+	}
+	if v2.RootHash() != BuildMerkleTree(files).Root.Hash {
+		t.Error("Update modified the original tree")
+	}
+
+	updated := append([]File{}, files...)
+	updated[1] = File{Data: "changed"}
+	if v3.RootHash() != BuildMerkleTree(updated).Root.Hash {
+		t.Error("Update produced an unexpected root")
+	}
+
+	// The right half of the tree is untouched by updating leaf 1 and must be shared
+	if v3.root.right != v2.root.right {
+		t.Error("Update did not share the unchanged subtree")
+	}
+}
+
+func TestPersistentTreeOutOfRange(t *testing.T) {
+	tree := NewPersistentTree(testFiles(2))
+
+	if _, _, err := tree.GenerateProof(2); err == nil {
+		t.Error("GenerateProof should return an error for out of range index")
+	}
+	if _, err := tree.Update(-1, File{}); err == nil {
+		t.Error("Update should return an error for negative index")
+	}
+	if (&PersistentTree{}).RootHash() != [32]byte{} {
+		t.Error("Empty tree should have a zero root hash")
+	}
+}
+
+func TestPersistentTreeNodeHash(t *testing.T) {
+	files := testFiles(11)
+	tree := BuildMerkleTree(files)
+	persistent := NewPersistentTree(files)
+
+	for level, size := range levelSizes(len(files)) {
+		for index := 0; index < size; index++ {
+			want, _ := tree.NodeHash(level, index)
+			got, err := persistent.NodeHash(level, index)
+			if err != nil {
+				t.Fatalf("NodeHash(%d, %d) returned an error: %v", level, index, err)
+			}
+			if got != want {
+				t.Errorf("NodeHash(%d, %d) mismatch", level, index)
+			}
+		}
+	}
+
+	if _, err := persistent.NodeHash(1, 6); err == nil {
+		t.Error("NodeHash should return an error for a node past the last leaf")
+	}
+}
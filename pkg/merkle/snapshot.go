@@ -0,0 +1,250 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// SnapshotFormat selects what a snapshot stores
+type SnapshotFormat uint8
+
+const (
+	// SnapshotFull stores every node hash, level by level, so loading needs no hashing
+	SnapshotFull SnapshotFormat = iota
+	// SnapshotLeaves stores only leaf hashes; internal nodes are rehashed on load
+	SnapshotLeaves
+)
+
+// VerifyMode selects how much of a full snapshot is re-verified on load
+type VerifyMode int
+
+const (
+	// VerifySpotCheck recomputes a random sample of internal nodes from their children
+	VerifySpotCheck VerifyMode = iota
+	// VerifyFull recomputes every internal node from the leaf hashes
+	VerifyFull
+)
+
+// ParseVerifyMode parses "spot" or "full" into a VerifyMode
+func ParseVerifyMode(s string) (VerifyMode, error) {
+	switch s {
+	case "spot":
+		return VerifySpotCheck, nil
+	case "full":
+		return VerifyFull, nil
+	}
+	return 0, fmt.Errorf("unknown verify mode %q", s)
+}
+
+const (
+	snapshotVersion = 1
+	// snapshotHeaderSize covers magic, version, format, two reserved bytes, the leaf count and the checksum
+	snapshotHeaderSize = 4 + 1 + 1 + 2 + 8 + sha256.Size
+	// spotCheckSamples is the number of internal nodes VerifySpotCheck recomputes
+	spotCheckSamples = 64
+)
+
+var snapshotMagic = [4]byte{'M', 'R', 'K', 'L'}
+
+// WriteSnapshot writes the tree to w as a versioned binary snapshot. The
+// header carries a SHA-256 checksum of the body so that truncated or corrupted
+// files are rejected on load.
+func WriteSnapshot[T any](w io.Writer, t *Tree[T], format SnapshotFormat) error {
+	levels := t.levels()
+	if format == SnapshotLeaves && len(levels) > 1 {
+		levels = levels[:1]
+	}
+
+	var body bytes.Buffer
+	for _, level := range levels {
+		for _, hash := range level {
+			body.Write(hash[:])
+		}
+	}
+
+	header := make([]byte, 0, snapshotHeaderSize)
+	header = append(header, snapshotMagic[:]...)
+	header = append(header, snapshotVersion, byte(format), 0, 0)
+	header = binary.BigEndian.AppendUint64(header, uint64(len(t.Leaves)))
+	checksum := sha256.Sum256(body.Bytes())
+	header = append(header, checksum[:]...)
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("writing snapshot header: %w", err)
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return fmt.Errorf("writing snapshot body: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot loads a tree written by WriteSnapshot. The checksum is always
+// checked; mode controls how much of the stored node hashes is re-verified.
+func ReadSnapshot[T any](r io.Reader, encode LeafEncoder[T], mode VerifyMode) (*Tree[T], error) {
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading snapshot header: %w", err)
+	}
+	if !bytes.Equal(header[:4], snapshotMagic[:]) {
+		return nil, fmt.Errorf("not a tree snapshot")
+	}
+	if header[4] != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", header[4])
+	}
+	format := SnapshotFormat(header[5])
+	if format != SnapshotFull && format != SnapshotLeaves {
+		return nil, fmt.Errorf("unknown snapshot format %d", format)
+	}
+	leafCount := binary.BigEndian.Uint64(header[8:16])
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot body: %w", err)
+	}
+	if sha256.Sum256(body) != [32]byte(header[16:]) {
+		return nil, fmt.Errorf("snapshot checksum mismatch")
+	}
+
+	sizes := levelSizes(int(leafCount))
+	if format == SnapshotLeaves {
+		sizes = sizes[:min(len(sizes), 1)]
+	}
+	nodeCount := 0
+	for _, size := range sizes {
+		nodeCount += size
+	}
+	if len(body) != nodeCount*32 {
+		return nil, fmt.Errorf("snapshot holds %d bytes, expected %d", len(body), nodeCount*32)
+	}
+
+	if leafCount == 0 {
+		return &Tree[T]{encode: encode}, nil
+	}
+
+	levels := make([][][32]byte, len(sizes))
+	for i, size := range sizes {
+		levels[i] = make([][32]byte, size)
+		for j := range levels[i] {
+			levels[i][j] = [32]byte(body[:32])
+			body = body[32:]
+		}
+	}
+
+	if format == SnapshotLeaves || mode == VerifyFull {
+		tree := BuildTreeFromHashes(levels[0], encode)
+		if format == SnapshotFull && tree.Root != nil && tree.Root.Hash != levels[len(levels)-1][0] {
+			return nil, fmt.Errorf("snapshot root does not match its leaves")
+		}
+		return tree, nil
+	}
+
+	if err := spotCheck(levels); err != nil {
+		return nil, err
+	}
+	return treeFromLevels(levels, encode), nil
+}
+
+// levels returns the node hashes of the tree level by level, from the leaves to the root
+func (t *Tree[T]) levels() [][][32]byte {
+	if len(t.Leaves) == 0 {
+		return nil
+	}
+
+	var levels [][][32]byte
+	nodes := t.Leaves
+	for {
+		hashes := make([][32]byte, len(nodes))
+		var parents []*Node
+		for i, node := range nodes {
+			hashes[i] = node.Hash
+			if i%2 == 0 && node.Parent != nil {
+				parents = append(parents, node.Parent)
+			}
+		}
+		levels = append(levels, hashes)
+		if len(parents) == 0 {
+			return levels
+		}
+		nodes = parents
+	}
+}
+
+// levelSizes returns the number of nodes on each level of a tree with n leaves
+func levelSizes(n int) []int {
+	if n == 0 {
+		return nil
+	}
+	sizes := []int{n}
+	for n > 1 {
+		n = (n + 1) / 2
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
+
+// spotCheck recomputes the root and a random sample of internal nodes from their children
+func spotCheck(levels [][][32]byte) error {
+	check := func(level, index int) error {
+		children := levels[level-1]
+		left := children[2*index]
+		right := left
+		if 2*index+1 < len(children) {
+			right = children[2*index+1]
+		}
+		if HashPair(left[:], right[:]) != levels[level][index] {
+			return fmt.Errorf("snapshot node %d on level %d does not match its children", index, level)
+		}
+		return nil
+	}
+
+	if len(levels) < 2 {
+		return nil
+	}
+	if err := check(len(levels)-1, 0); err != nil {
+		return err
+	}
+	for i := 0; i < spotCheckSamples; i++ {
+		level := 1 + rand.Intn(len(levels)-1)
+		if err := check(level, rand.Intn(len(levels[level]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// treeFromLevels links stored node hashes into a tree without hashing
+func treeFromLevels[T any](levels [][][32]byte, encode LeafEncoder[T]) *Tree[T] {
+	tree := &Tree[T]{encode: encode}
+	if len(levels) == 0 {
+		return tree
+	}
+
+	var below []*Node
+	for l, hashes := range levels {
+		nodes := make([]*Node, len(hashes))
+		for i, hash := range hashes {
+			nodes[i] = &Node{Hash: hash}
+			if l == 0 {
+				continue
+			}
+			left := below[2*i]
+			right := &Node{Hash: left.Hash} // Duplicate last node if number of nodes is odd
+			if 2*i+1 < len(below) {
+				right = below[2*i+1]
+			}
+			nodes[i].Left, nodes[i].Right = left, right
+			left.Parent, right.Parent = nodes[i], nodes[i]
+		}
+		if l == 0 {
+			tree.Leaves = nodes
+		}
+		below = nodes
+	}
+
+	tree.Root = below[0]
+	return tree
+}
@@ -0,0 +1,92 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 100} {
+		tree := BuildMerkleTree(testFiles(n))
+		for _, format := range []SnapshotFormat{SnapshotFull, SnapshotLeaves} {
+			for _, mode := range []VerifyMode{VerifySpotCheck, VerifyFull} {
+				var buf bytes.Buffer
+				if err := WriteSnapshot(&buf, tree, format); err != nil {
+					t.Fatalf("n=%d: WriteSnapshot returned an error: %v", n, err)
+				}
+
+				loaded, err := ReadSnapshot(&buf, EncodeFile, mode)
+				if err != nil {
+					t.Fatalf("n=%d format=%d mode=%d: ReadSnapshot returned an error: %v", n, format, mode, err)
+				}
+				if len(loaded.Leaves) != n {
+					t.Errorf("n=%d: expected %d leaves, got %d", n, n, len(loaded.Leaves))
+				}
+				if n > 0 && loaded.Root.Hash != tree.Root.Hash {
+					t.Errorf("n=%d format=%d mode=%d: root mismatch", n, format, mode)
+				}
+				for i := 0; i < n; i++ {
+					wantProof, wantDirections, _ := tree.GenerateProof(i)
+					proof, directions, _ := loaded.GenerateProof(i)
+					if !sameProof(proof, wantProof, directions, wantDirections) {
+						t.Errorf("n=%d i=%d: proof mismatch after reload", n, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSnapshotDetectsCorruption(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, BuildMerkleTree(testFiles(8)), SnapshotFull); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := ReadSnapshot(bytes.NewReader(corrupted), EncodeFile, VerifySpotCheck); err == nil {
+		t.Error("ReadSnapshot should reject a snapshot with a bad checksum")
+	}
+
+	if _, err := ReadSnapshot(bytes.NewReader(data[:len(data)-32]), EncodeFile, VerifySpotCheck); err == nil {
+		t.Error("ReadSnapshot should reject a truncated snapshot")
+	}
+
+	wrongVersion := append([]byte{}, data...)
+	wrongVersion[4] = 99
+	if _, err := ReadSnapshot(bytes.NewReader(wrongVersion), EncodeFile, VerifySpotCheck); err == nil {
+		t.Error("ReadSnapshot should reject an unknown version")
+	}
+}
+
+func TestSnapshotVerifyFullDetectsBadNode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, BuildMerkleTree(testFiles(8)), SnapshotFull); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// Replace the root with garbage and fix up the checksum so only the node check can catch it
+	body := data[snapshotHeaderSize:]
+	copy(body[len(body)-32:], bytes.Repeat([]byte{0xaa}, 32))
+	checksum := sha256.Sum256(body)
+	copy(data[16:snapshotHeaderSize], checksum[:])
+
+	for _, mode := range []VerifyMode{VerifySpotCheck, VerifyFull} {
+		if _, err := ReadSnapshot(bytes.NewReader(data), EncodeFile, mode); err == nil {
+			t.Errorf("mode=%d: ReadSnapshot should reject a snapshot with an inconsistent root", mode)
+		}
+	}
+}
+
+func TestParseVerifyMode(t *testing.T) {
+	if mode, err := ParseVerifyMode("full"); err != nil || mode != VerifyFull {
+		t.Error("ParseVerifyMode should parse full")
+	}
+	if _, err := ParseVerifyMode("sometimes"); err == nil {
+		t.Error("ParseVerifyMode should reject unknown modes")
+	}
+}
@@ -0,0 +1,107 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// HashFunc hashes arbitrary data into a 32-byte digest
+type HashFunc func(data []byte) [32]byte
+
+// SortedTree is a Merkle tree with commutative pair hashing: the two children
+// are sorted before hashing, so proofs need no direction bits. Nodes are laid
+// out as a complete binary tree in a single array (children of i at 2i+1 and
+// 2i+2) with the sorted leaves at the end in reverse order, which is the
+// layout used by OpenZeppelin's merkle-tree library and verified on-chain by
+// its MerkleProof contract.
+type SortedTree struct {
+	Nodes [][32]byte
+	Hash  HashFunc
+	// positions maps the index of each input leaf to its node index
+	positions []int
+}
+
+// SortedHashPair hashes the two nodes in ascending byte order
+func SortedHashPair(hash HashFunc, a, b [32]byte) [32]byte {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return hash(append(a[:], b[:]...))
+}
+
+// BuildSortedTree constructs a sorted-pair tree over the given leaf hashes. A
+// nil hash uses CreateHash.
+func BuildSortedTree(leaves [][32]byte, hash HashFunc) (*SortedTree, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("expected at least one leaf")
+	}
+	if hash == nil {
+		hash = CreateHash
+	}
+
+	order := make([]int, len(leaves))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return bytes.Compare(leaves[order[i]][:], leaves[order[j]][:]) < 0
+	})
+
+	nodes := make([][32]byte, 2*len(leaves)-1)
+	positions := make([]int, len(leaves))
+	for rank, leaf := range order {
+		position := len(nodes) - 1 - rank
+		nodes[position] = leaves[leaf]
+		positions[leaf] = position
+	}
+	for i := len(nodes) - len(leaves) - 1; i >= 0; i-- {
+		nodes[i] = SortedHashPair(hash, nodes[2*i+1], nodes[2*i+2])
+	}
+
+	return &SortedTree{Nodes: nodes, Hash: hash, positions: positions}, nil
+}
+
+// Root returns the root hash of the tree
+func (t *SortedTree) Root() [32]byte {
+	return t.Nodes[0]
+}
+
+// TreeIndex returns the node index of the leaf passed at position index to BuildSortedTree
+func (t *SortedTree) TreeIndex(index int) (int, error) {
+	if index < 0 || index >= len(t.positions) {
+		return 0, fmt.Errorf("index out of range")
+	}
+	return t.positions[index], nil
+}
+
+// GenerateProof generates a directionless proof, ordered from the leaf up,
+// for the leaf passed at position index to BuildSortedTree.
+func (t *SortedTree) GenerateProof(index int) ([][32]byte, error) {
+	node, err := t.TreeIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var proof [][32]byte
+	for node > 0 {
+		sibling := node + 1
+		if node%2 == 0 {
+			sibling = node - 1
+		}
+		proof = append(proof, t.Nodes[sibling])
+		node = (node - 1) / 2
+	}
+	return proof, nil
+}
+
+// VerifySortedProof checks a directionless proof for leaf against root. A nil hash uses CreateHash.
+func VerifySortedProof(hash HashFunc, leaf [32]byte, proof [][32]byte, root [32]byte) bool {
+	if hash == nil {
+		hash = CreateHash
+	}
+	for _, sibling := range proof {
+		leaf = SortedHashPair(hash, leaf, sibling)
+	}
+	return leaf == root
+}
@@ -0,0 +1,110 @@
+package merkle
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func leafHashes(n int) [][32]byte {
+	leaves := make([][32]byte, n)
+	for i := range leaves {
+		leaves[i] = CreateHash([]byte(fmt.Sprintf("leaf%d", i)))
+	}
+	return leaves
+}
+
+func TestSortedTreeProofs(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		leaves := leafHashes(n)
+		tree, err := BuildSortedTree(leaves, nil)
+		if err != nil {
+			t.Fatalf("n=%d: BuildSortedTree returned an error: %v", n, err)
+		}
+		if len(tree.Nodes) != 2*n-1 {
+			t.Errorf("n=%d: expected %d nodes, got %d", n, 2*n-1, len(tree.Nodes))
+		}
+
+		for i, leaf := range leaves {
+			proof, err := tree.GenerateProof(i)
+			if err != nil {
+				t.Fatalf("n=%d i=%d: GenerateProof returned an error: %v", n, i, err)
+			}
+			if !VerifySortedProof(nil, leaf, proof, tree.Root()) {
+				t.Errorf("n=%d i=%d: proof did not verify", n, i)
+			}
+		}
+	}
+}
+
+func TestSortedTreeIsOrderIndependent(t *testing.T) {
+	leaves := leafHashes(5)
+	reversed := make([][32]byte, len(leaves))
+	for i, leaf := range leaves {
+		reversed[len(leaves)-1-i] = leaf
+	}
+
+	a, _ := BuildSortedTree(leaves, nil)
+	b, _ := BuildSortedTree(reversed, nil)
+	if a.Root() != b.Root() {
+		t.Error("Sorted tree root should not depend on leaf input order")
+	}
+}
+
+func TestSortedHashPairIsCommutative(t *testing.T) {
+	a, b := CreateHash([]byte("a")), CreateHash([]byte("b"))
+	if SortedHashPair(CreateHash, a, b) != SortedHashPair(CreateHash, b, a) {
+		t.Error("SortedHashPair should be commutative")
+	}
+}
+
+func TestBuildSortedTreeEmptyInput(t *testing.T) {
+	if _, err := BuildSortedTree(nil, nil); err == nil {
+		t.Error("BuildSortedTree should return an error for empty input")
+	}
+}
+
+// doubleHashString hashes a single string value twice, mirroring the
+// StandardMerkleTree double hashing of leaves.
+func doubleHashString(value []interface{}) ([32]byte, error) {
+	s, ok := value[0].(string)
+	if !ok {
+		return [32]byte{}, fmt.Errorf("expected string, got %T", value[0])
+	}
+	inner := CreateHash([]byte(s))
+	return CreateHash(inner[:]), nil
+}
+
+func TestStandardTreeDumpRoundTrip(t *testing.T) {
+	values := [][]interface{}{{"alice"}, {"bob"}, {"carol"}}
+	tree, err := NewStandardTree(values, []string{"string"}, doubleHashString, nil)
+	if err != nil {
+		t.Fatalf("NewStandardTree returned an error: %v", err)
+	}
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+
+	var dump StandardTreeDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+	if dump.Format != StandardTreeFormat || len(dump.Tree) != 5 || len(dump.Values) != 3 {
+		t.Errorf("Unexpected dump: %s", data)
+	}
+
+	loaded, err := LoadStandardTree(&dump, doubleHashString, nil)
+	if err != nil {
+		t.Fatalf("LoadStandardTree returned an error: %v", err)
+	}
+	if loaded.Root() != tree.Root() {
+		t.Error("Loaded tree root doesn't match the original")
+	}
+
+	dump.Values[0].Value = []interface{}{"mallory"}
+	if _, err := LoadStandardTree(&dump, doubleHashString, nil); err == nil {
+		t.Error("LoadStandardTree should reject a dump whose values don't match its nodes")
+	}
+}
@@ -0,0 +1,122 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StandardTreeFormat is the format tag of an OpenZeppelin StandardMerkleTree dump
+const StandardTreeFormat = "standard-v1"
+
+// LeafHasher computes the leaf hash of a typed value in a StandardTree
+type LeafHasher func(value []interface{}) ([32]byte, error)
+
+// StandardTree is a sorted-pair tree over typed values that can be exported
+// in the JSON dump format of OpenZeppelin's StandardMerkleTree.
+type StandardTree struct {
+	LeafEncoding []string
+	Values       [][]interface{}
+	Tree         *SortedTree
+}
+
+// StandardTreeDump is the JSON representation of a StandardTree
+type StandardTreeDump struct {
+	Format       string              `json:"format"`
+	LeafEncoding []string            `json:"leafEncoding"`
+	Tree         []string            `json:"tree"`
+	Values       []StandardTreeValue `json:"values"`
+}
+
+// StandardTreeValue is a value in a StandardTreeDump together with its node index
+type StandardTreeValue struct {
+	Value     []interface{} `json:"value"`
+	TreeIndex int           `json:"treeIndex"`
+}
+
+// NewStandardTree hashes each value with leafHash and builds a sorted-pair tree over the results
+func NewStandardTree(values [][]interface{}, leafEncoding []string, leafHash LeafHasher, hash HashFunc) (*StandardTree, error) {
+	leaves := make([][32]byte, len(values))
+	for i, value := range values {
+		if len(value) != len(leafEncoding) {
+			return nil, fmt.Errorf("value %d has %d fields, leaf encoding has %d", i, len(value), len(leafEncoding))
+		}
+		leaf, err := leafHash(value)
+		if err != nil {
+			return nil, fmt.Errorf("hashing value %d: %w", i, err)
+		}
+		leaves[i] = leaf
+	}
+
+	tree, err := BuildSortedTree(leaves, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &StandardTree{LeafEncoding: leafEncoding, Values: values, Tree: tree}, nil
+}
+
+// Root returns the root hash of the tree
+func (t *StandardTree) Root() [32]byte {
+	return t.Tree.Root()
+}
+
+// GenerateProof generates a directionless proof for the value at index
+func (t *StandardTree) GenerateProof(index int) ([][32]byte, error) {
+	return t.Tree.GenerateProof(index)
+}
+
+// Dump returns the tree in the StandardMerkleTree dump format
+func (t *StandardTree) Dump() *StandardTreeDump {
+	dump := &StandardTreeDump{
+		Format:       StandardTreeFormat,
+		LeafEncoding: t.LeafEncoding,
+		Tree:         make([]string, len(t.Tree.Nodes)),
+		Values:       make([]StandardTreeValue, len(t.Values)),
+	}
+	for i, node := range t.Tree.Nodes {
+		dump.Tree[i] = "0x" + hex.EncodeToString(node[:])
+	}
+	for i, value := range t.Values {
+		dump.Values[i] = StandardTreeValue{Value: value, TreeIndex: t.Tree.positions[i]}
+	}
+	return dump
+}
+
+// MarshalJSON encodes the tree as its StandardMerkleTree dump
+func (t *StandardTree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Dump())
+}
+
+// LoadStandardTree rebuilds a tree from a dump, rehashing every value and node
+// to check that the dump is consistent.
+func LoadStandardTree(dump *StandardTreeDump, leafHash LeafHasher, hash HashFunc) (*StandardTree, error) {
+	if dump.Format != StandardTreeFormat {
+		return nil, fmt.Errorf("unknown tree format %q", dump.Format)
+	}
+
+	values := make([][]interface{}, len(dump.Values))
+	for i, value := range dump.Values {
+		values[i] = value.Value
+	}
+	tree, err := NewStandardTree(values, dump.LeafEncoding, leafHash, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dump.Tree) != len(tree.Tree.Nodes) {
+		return nil, fmt.Errorf("tree has %d nodes, expected %d", len(dump.Tree), len(tree.Tree.Nodes))
+	}
+	for i, node := range dump.Tree {
+		decoded, err := hex.DecodeString(strings.TrimPrefix(node, "0x"))
+		if err != nil || len(decoded) != 32 || [32]byte(decoded) != tree.Tree.Nodes[i] {
+			return nil, fmt.Errorf("node %d does not match the values", i)
+		}
+	}
+	for i, value := range dump.Values {
+		if value.TreeIndex != tree.Tree.positions[i] {
+			return nil, fmt.Errorf("value %d has tree index %d, expected %d", i, value.TreeIndex, tree.Tree.positions[i])
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,88 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// cosignaturePrefix separates witness cosignatures from tree head signatures
+const cosignaturePrefix = "go-merkle cosignature v1\n"
+
+// Cosignature is a witness's statement that a tree head is consistent with
+// every tree head the witness cosigned before it
+type Cosignature struct {
+	// WitnessKey is the hex-encoded public key of the witness
+	WitnessKey string `json:"witnessKey"`
+	// Timestamp is when the witness cosigned, in milliseconds since the Unix epoch
+	Timestamp int64  `json:"timestamp"`
+	Signature []byte `json:"signature"`
+}
+
+// Cosign creates a cosignature on the tree head with the witness key
+func Cosign(key ed25519.PrivateKey, sth *SignedTreeHead, timestamp time.Time) (Cosignature, error) {
+	root, err := sth.Root()
+	if err != nil {
+		return Cosignature{}, err
+	}
+
+	public := key.Public().(ed25519.PublicKey)
+	cosig := Cosignature{
+		WitnessKey: hex.EncodeToString(public),
+		Timestamp:  timestamp.UnixMilli(),
+	}
+	cosig.Signature = ed25519.Sign(key, cosig.signedBytes(sth, root))
+	return cosig, nil
+}
+
+// Verify checks the cosignature on the tree head against the witness key
+func (c Cosignature) Verify(key ed25519.PublicKey, sth *SignedTreeHead) error {
+	if c.WitnessKey != hex.EncodeToString(key) {
+		return errors.New("cosignature is from a different witness")
+	}
+	root, err := sth.Root()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, c.signedBytes(sth, root), c.Signature) {
+		return errors.New("invalid cosignature")
+	}
+	return nil
+}
+
+// signedBytes is the message covered by the cosignature
+func (c Cosignature) signedBytes(sth *SignedTreeHead, root [32]byte) []byte {
+	msg := []byte(cosignaturePrefix)
+	msg = append(msg, sth.signedBytes(root)...)
+	return binary.BigEndian.AppendUint64(msg, uint64(c.Timestamp))
+}
+
+// VerifyQuorum checks that at least quorum of the given witnesses cosigned
+// the tree head. A witness listed twice counts once, and the quorum must be
+// at least 1.
+func (sth *SignedTreeHead) VerifyQuorum(witnesses []ed25519.PublicKey, quorum int) error {
+	if quorum < 1 {
+		return fmt.Errorf("quorum must be at least 1, got %d", quorum)
+	}
+
+	counted := map[string]bool{}
+	for _, witness := range witnesses {
+		key := hex.EncodeToString(witness)
+		if counted[key] {
+			continue
+		}
+		for _, cosig := range sth.Cosignatures {
+			if cosig.Verify(witness, sth) == nil {
+				counted[key] = true
+				break
+			}
+		}
+	}
+	if len(counted) < quorum {
+		return fmt.Errorf("tree head has %d valid cosignatures from known witnesses, need %d", len(counted), quorum)
+	}
+	return nil
+}
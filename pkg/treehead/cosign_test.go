@@ -0,0 +1,63 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestCosignAndVerify(t *testing.T) {
+	_, logKey, _ := ed25519.GenerateKey(nil)
+	witnessPublic, witnessKey, _ := ed25519.GenerateKey(nil)
+
+	sth := Sign(logKey, 3, [32]byte{1}, time.UnixMilli(1700000000000))
+	cosig, err := Cosign(witnessKey, sth, time.Now())
+	if err != nil {
+		t.Fatalf("Cosign returned an error: %v", err)
+	}
+	if err := cosig.Verify(witnessPublic, sth); err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+
+	other := Sign(logKey, 3, [32]byte{2}, time.UnixMilli(1700000000000))
+	if cosig.Verify(witnessPublic, other) == nil {
+		t.Error("Verify should reject a cosignature on another tree head")
+	}
+
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+	if cosig.Verify(otherPublic, sth) == nil {
+		t.Error("Verify should reject a cosignature checked against another witness")
+	}
+}
+
+func TestVerifyQuorum(t *testing.T) {
+	_, logKey, _ := ed25519.GenerateKey(nil)
+	sth := Sign(logKey, 3, [32]byte{1}, time.Now())
+
+	var witnesses []ed25519.PublicKey
+	for i := 0; i < 3; i++ {
+		public, private, _ := ed25519.GenerateKey(nil)
+		witnesses = append(witnesses, public)
+		if i < 2 {
+			cosig, _ := Cosign(private, sth, time.Now())
+			// Duplicates must not count twice towards the quorum
+			sth.Cosignatures = append(sth.Cosignatures, cosig, cosig)
+		}
+	}
+
+	if err := sth.VerifyQuorum(witnesses, 2); err != nil {
+		t.Errorf("VerifyQuorum returned an error for 2 of 3: %v", err)
+	}
+	if sth.VerifyQuorum(witnesses, 3) == nil {
+		t.Error("VerifyQuorum should fail when fewer witnesses than the quorum cosigned")
+	}
+	if sth.VerifyQuorum(witnesses[2:], 1) == nil {
+		t.Error("VerifyQuorum should ignore cosignatures from unknown witnesses")
+	}
+	if sth.VerifyQuorum([]ed25519.PublicKey{witnesses[0], witnesses[0]}, 2) == nil {
+		t.Error("VerifyQuorum should count a witness listed twice only once")
+	}
+	if sth.VerifyQuorum(witnesses, 0) == nil {
+		t.Error("VerifyQuorum should reject a quorum below 1")
+	}
+}
@@ -0,0 +1,76 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// promisePrefix separates inclusion promises from tree head signatures
+const promisePrefix = "go-merkle inclusion promise v1\n"
+
+// InclusionPromise is a server's signed commitment that a leaf will appear
+// at Index in a signed tree head no later than Timestamp plus MaxMergeDelay
+type InclusionPromise struct {
+	// LeafHash is the hex-encoded leaf hash
+	LeafHash string `json:"leafHash"`
+	Index    int    `json:"index"`
+	// Timestamp is when the leaf was accepted, in milliseconds since the Unix epoch
+	Timestamp int64 `json:"timestamp"`
+	// MaxMergeDelay is in milliseconds
+	MaxMergeDelay int64  `json:"maxMergeDelay"`
+	Signature     []byte `json:"signature"`
+}
+
+// Promise creates an inclusion promise for the leaf, signed with key
+func Promise(key ed25519.PrivateKey, leafHash [32]byte, index int, timestamp time.Time, maxMergeDelay time.Duration) *InclusionPromise {
+	p := &InclusionPromise{
+		LeafHash:      hex.EncodeToString(leafHash[:]),
+		Index:         index,
+		Timestamp:     timestamp.UnixMilli(),
+		MaxMergeDelay: maxMergeDelay.Milliseconds(),
+	}
+	p.Signature = ed25519.Sign(key, p.signedBytes(leafHash))
+	return p
+}
+
+// Leaf decodes the leaf hash
+func (p *InclusionPromise) Leaf() ([32]byte, error) {
+	leaf, err := hex.DecodeString(p.LeafHash)
+	if err != nil || len(leaf) != 32 {
+		return [32]byte{}, fmt.Errorf("invalid leaf hash %q", p.LeafHash)
+	}
+	return [32]byte(leaf), nil
+}
+
+// Deadline returns the time by which the leaf must be in a signed tree head
+func (p *InclusionPromise) Deadline() time.Time {
+	return time.UnixMilli(p.Timestamp + p.MaxMergeDelay)
+}
+
+// Verify checks the signature on the promise against the public key
+func (p *InclusionPromise) Verify(key ed25519.PublicKey) error {
+	leaf, err := p.Leaf()
+	if err != nil {
+		return err
+	}
+	if p.Index < 0 || p.MaxMergeDelay < 0 {
+		return fmt.Errorf("invalid inclusion promise")
+	}
+	if !ed25519.Verify(key, p.signedBytes(leaf), p.Signature) {
+		return errors.New("invalid inclusion promise signature")
+	}
+	return nil
+}
+
+// signedBytes is the message covered by the signature
+func (p *InclusionPromise) signedBytes(leaf [32]byte) []byte {
+	msg := []byte(promisePrefix)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(p.Index))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(p.Timestamp))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(p.MaxMergeDelay))
+	return append(msg, leaf[:]...)
+}
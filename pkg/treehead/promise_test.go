@@ -0,0 +1,35 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func TestPromiseSignAndVerify(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	leaf := [32]byte{7}
+	accepted := time.UnixMilli(1700000000000)
+
+	promise := Promise(private, leaf, 4, accepted, time.Minute)
+	if err := promise.Verify(public); err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+	if decoded, err := promise.Leaf(); err != nil || decoded != leaf {
+		t.Error("Leaf should decode the promised leaf hash")
+	}
+	if !promise.Deadline().Equal(accepted.Add(time.Minute)) {
+		t.Errorf("Deadline should be the timestamp plus the merge delay, got %s", promise.Deadline())
+	}
+
+	tampered := *promise
+	tampered.MaxMergeDelay = time.Hour.Milliseconds()
+	if tampered.Verify(public) == nil {
+		t.Error("Verify should reject a promise with a modified merge delay")
+	}
+	tampered = *promise
+	tampered.Index = 5
+	if tampered.Verify(public) == nil {
+		t.Error("Verify should reject a promise with a modified index")
+	}
+}
@@ -0,0 +1,88 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+// TimestampReceipt proves that a document hash was included in a signed
+// tree head. It is self-contained: verifying it needs only the server's
+// public key, not the server.
+type TimestampReceipt struct {
+	// Hash is the hex-encoded SHA-256 hash of the timestamped document
+	Hash string `json:"hash"`
+	// Index is the leaf holding the hash; the leaf hash is the SHA-256 of the hash bytes
+	Index      int             `json:"index"`
+	Proof      []string        `json:"proof"`
+	Directions []bool          `json:"directions"`
+	TreeHead   *SignedTreeHead `json:"treeHead"`
+}
+
+// NewTimestampReceipt creates a receipt for hash at index from a root-first inclusion proof
+func NewTimestampReceipt(hash [32]byte, index int, proof [][32]byte, directions []bool, sth *SignedTreeHead) *TimestampReceipt {
+	encoded := make([]string, len(proof))
+	for i, sibling := range proof {
+		encoded[i] = hex.EncodeToString(sibling[:])
+	}
+	return &TimestampReceipt{
+		Hash:       hex.EncodeToString(hash[:]),
+		Index:      index,
+		Proof:      encoded,
+		Directions: directions,
+		TreeHead:   sth,
+	}
+}
+
+// DocumentHash decodes the timestamped hash
+func (r *TimestampReceipt) DocumentHash() ([32]byte, error) {
+	hash, err := hex.DecodeString(r.Hash)
+	if err != nil || len(hash) != 32 {
+		return [32]byte{}, fmt.Errorf("invalid document hash %q", r.Hash)
+	}
+	return [32]byte(hash), nil
+}
+
+// Verify checks the tree head signature and the inclusion proof, and returns
+// the time of the tree head: the document existed no later than that time.
+func (r *TimestampReceipt) Verify(key ed25519.PublicKey) (time.Time, error) {
+	if r.TreeHead == nil {
+		return time.Time{}, errors.New("receipt has no tree head")
+	}
+	if err := r.TreeHead.Verify(key); err != nil {
+		return time.Time{}, err
+	}
+	if r.Index < 0 || r.Index >= r.TreeHead.TreeSize {
+		return time.Time{}, fmt.Errorf("index %d is outside the tree of size %d", r.Index, r.TreeHead.TreeSize)
+	}
+
+	hash, err := r.DocumentHash()
+	if err != nil {
+		return time.Time{}, err
+	}
+	proof := make([][32]byte, len(r.Proof))
+	for i, encoded := range r.Proof {
+		sibling, err := hex.DecodeString(encoded)
+		if err != nil || len(sibling) != 32 {
+			return time.Time{}, fmt.Errorf("invalid proof hash %q", encoded)
+		}
+		proof[i] = [32]byte(sibling)
+	}
+	root, err := r.TreeHead.Root()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The proof must be for the leaf at Index, in either tree structure the server may use
+	leaf := merkle.CreateHash(hash[:])
+	size := r.TreeHead.TreeSize
+	if !merkle.VerifyProofAt(leaf, r.Index, size, proof, r.Directions, root) &&
+		!merkle.VerifyFlatMMRProofAt(leaf, r.Index, size, proof, r.Directions, root) {
+		return time.Time{}, errors.New("inclusion proof does not match the signed root")
+	}
+	return r.TreeHead.Time(), nil
+}
@@ -0,0 +1,65 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/akhilesharora/go-merkle/pkg/merkle"
+)
+
+func TestTimestampReceipt(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+
+	var hashes [][]byte
+	for i := 0; i < 5; i++ {
+		hash := merkle.CreateHash([]byte{byte(i)})
+		hashes = append(hashes, hash[:])
+	}
+	tree := merkle.BuildTree(hashes, merkle.EncodeBytes)
+	signedAt := time.UnixMilli(1700000000000)
+	sth := Sign(private, 5, tree.Root.Hash, signedAt)
+
+	proof, directions, _ := tree.GenerateProof(3)
+	receipt := NewTimestampReceipt([32]byte(hashes[3]), 3, proof, directions, sth)
+	at, err := receipt.Verify(public)
+	if err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+	if !at.Equal(signedAt) {
+		t.Errorf("Verify should return the tree head time, got %s", at)
+	}
+
+	tampered := *receipt
+	tampered.Hash = receipt.Proof[0]
+	if _, err := tampered.Verify(public); err == nil {
+		t.Error("Verify should reject a receipt for another hash")
+	}
+	tampered = *receipt
+	tampered.Index = 2
+	if _, err := tampered.Verify(public); err == nil {
+		t.Error("Verify should reject a proof for another index")
+	}
+	tampered = *receipt
+	tampered.Index = 5
+	if _, err := tampered.Verify(public); err == nil {
+		t.Error("Verify should reject an index outside the tree")
+	}
+
+	// Servers in mmr mode issue flattened mountain range proofs
+	m := merkle.NewMountainRange(nil)
+	for _, hash := range hashes {
+		m.Append(merkle.File{Data: string(hash)})
+	}
+	mmrProof, _ := m.GenerateProof(4)
+	proof, directions = mmrProof.Flatten()
+	mmrReceipt := NewTimestampReceipt([32]byte(hashes[4]), 4, proof, directions, Sign(private, 5, m.Root(), signedAt))
+	if _, err := mmrReceipt.Verify(public); err != nil {
+		t.Errorf("Verify returned an error for a mountain range receipt: %v", err)
+	}
+
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+	if _, err := receipt.Verify(otherPublic); err == nil {
+		t.Error("Verify should reject a receipt signed by another key")
+	}
+}
@@ -0,0 +1,111 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// signaturePrefix separates tree head signatures from any other use of the key
+const signaturePrefix = "go-merkle tree head v1\n"
+
+// SignedTreeHead is a server's signed statement of its tree size and root hash at a point in time
+type SignedTreeHead struct {
+	TreeSize int `json:"treeSize"`
+	// RootHash is the hex-encoded root hash
+	RootHash string `json:"rootHash"`
+	// Timestamp is in milliseconds since the Unix epoch
+	Timestamp int64  `json:"timestamp"`
+	Signature []byte `json:"signature"`
+	// Cosignatures are collected from witnesses and are not covered by Signature
+	Cosignatures []Cosignature `json:"cosignatures,omitempty"`
+}
+
+// Sign creates a tree head for the given size and root, signed with key
+func Sign(key ed25519.PrivateKey, treeSize int, rootHash [32]byte, timestamp time.Time) *SignedTreeHead {
+	sth := &SignedTreeHead{
+		TreeSize:  treeSize,
+		RootHash:  hex.EncodeToString(rootHash[:]),
+		Timestamp: timestamp.UnixMilli(),
+	}
+	sth.Signature = ed25519.Sign(key, sth.signedBytes(rootHash))
+	return sth
+}
+
+// Root decodes the root hash
+func (sth *SignedTreeHead) Root() ([32]byte, error) {
+	root, err := hex.DecodeString(sth.RootHash)
+	if err != nil || len(root) != 32 {
+		return [32]byte{}, fmt.Errorf("invalid root hash %q", sth.RootHash)
+	}
+	return [32]byte(root), nil
+}
+
+// Time returns the timestamp of the tree head
+func (sth *SignedTreeHead) Time() time.Time {
+	return time.UnixMilli(sth.Timestamp)
+}
+
+// Verify checks the signature on the tree head against the public key
+func (sth *SignedTreeHead) Verify(key ed25519.PublicKey) error {
+	root, err := sth.Root()
+	if err != nil {
+		return err
+	}
+	if sth.TreeSize < 0 {
+		return fmt.Errorf("invalid tree size %d", sth.TreeSize)
+	}
+	if !ed25519.Verify(key, sth.signedBytes(root), sth.Signature) {
+		return errors.New("invalid tree head signature")
+	}
+	return nil
+}
+
+// signedBytes is the message covered by the signature
+func (sth *SignedTreeHead) signedBytes(root [32]byte) []byte {
+	msg := []byte(signaturePrefix)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(sth.TreeSize))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(sth.Timestamp))
+	return append(msg, root[:]...)
+}
+
+// LoadOrCreateKey reads a hex-encoded Ed25519 seed from path, generating and
+// saving a new one if the file does not exist.
+func LoadOrCreateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		seed := hex.EncodeToString(key.Seed())
+		if err := os.WriteFile(path, []byte(seed+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("saving signing key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading signing key: %w", err)
+	}
+
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key %s is not a hex-encoded Ed25519 seed", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParsePublicKey decodes a hex-encoded Ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid Ed25519 public key %q", s)
+	}
+	return ed25519.PublicKey(key), nil
+}
@@ -0,0 +1,56 @@
+package treehead
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	root := [32]byte{1, 2, 3}
+
+	sth := Sign(private, 5, root, time.UnixMilli(1700000000000))
+	if err := sth.Verify(public); err != nil {
+		t.Fatalf("Verify returned an error: %v", err)
+	}
+
+	decoded, err := sth.Root()
+	if err != nil || decoded != root {
+		t.Error("Root should decode the signed root hash")
+	}
+
+	tampered := *sth
+	tampered.TreeSize = 6
+	if tampered.Verify(public) == nil {
+		t.Error("Verify should reject a tree head with a modified size")
+	}
+
+	otherPublic, _, _ := ed25519.GenerateKey(nil)
+	if sth.Verify(otherPublic) == nil {
+		t.Error("Verify should reject a signature from another key")
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.key")
+
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey returned an error: %v", err)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey returned an error on reload: %v", err)
+	}
+	if !created.Equal(loaded) {
+		t.Error("LoadOrCreateKey should return the saved key")
+	}
+
+	public, err := ParsePublicKey(hex.EncodeToString(created.Public().(ed25519.PublicKey)))
+	if err != nil || !public.Equal(created.Public()) {
+		t.Error("ParsePublicKey should decode a hex public key")
+	}
+}